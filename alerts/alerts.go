@@ -0,0 +1,68 @@
+// Package alerts delivers stock events outside the API, to the log or a webhook.
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"kasir-api/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Notifier receives low-stock events. Implementations must not block the caller.
+type Notifier interface {
+	LowStock(event models.LowStockEvent)
+}
+
+// LogNotifier writes events to the standard logger.
+type LogNotifier struct{}
+
+func (LogNotifier) LowStock(e models.LowStockEvent) {
+	log.Printf("LOW STOCK: %s (id: %d) stock %d, reorder point %d, reorder qty %d (transaction %d)",
+		e.ProductName, e.ProductID, e.Stock, e.ReorderPoint, e.ReorderQty, e.TransactionID)
+}
+
+// WebhookNotifier posts every event as JSON to URL in the background. Failed
+// deliveries are logged and not retried.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// webhookPayload wraps the event with its name so receivers can tell event kinds apart.
+type webhookPayload struct {
+	Event string               `json:"event"`
+	Data  models.LowStockEvent `json:"data"`
+}
+
+func (n *WebhookNotifier) LowStock(e models.LowStockEvent) {
+	go func() {
+		if err := n.post(webhookPayload{Event: "product.low_stock", Data: e}); err != nil {
+			log.Printf("low stock webhook for product %d failed: %v", e.ProductID, err)
+		}
+	}()
+}
+
+func (n *WebhookNotifier) post(payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.Client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
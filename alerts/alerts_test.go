@@ -0,0 +1,41 @@
+package alerts
+
+import (
+	"encoding/json"
+	"kasir-api/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookNotifier_PostsEvent(t *testing.T) {
+	received := make(chan webhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid webhook body: %s", err)
+		}
+		received <- payload
+	}))
+	defer server.Close()
+
+	n := NewWebhookNotifier(server.URL)
+	n.LowStock(models.LowStockEvent{ProductID: 1, ProductName: "Indomie", Stock: 2, ReorderPoint: 5, ReorderQty: 24, TransactionID: 9})
+
+	payload := <-received
+	if payload.Event != "product.low_stock" || payload.Data.ProductID != 1 || payload.Data.Stock != 2 {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+}
+
+func TestWebhookNotifier_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	n := NewWebhookNotifier(server.URL)
+	if err := n.post(webhookPayload{Event: "product.low_stock"}); err == nil {
+		t.Fatal("expected an error for a 502 response")
+	}
+}
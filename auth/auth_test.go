@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := HashPassword("rahasia123")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !CheckPassword(hash, "rahasia123") {
+		t.Error("expected password to match its hash")
+	}
+	if CheckPassword(hash, "salah") {
+		t.Error("expected wrong password to be rejected")
+	}
+	if CheckPassword("garbage", "rahasia123") {
+		t.Error("expected malformed hash to be rejected")
+	}
+}
+
+func TestToken(t *testing.T) {
+	secret := []byte("test-secret")
+	claims := Claims{UserID: 3, Username: "kasir1", Role: "cashier", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+
+	token, err := SignToken(claims, secret)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	parsed, err := ParseToken(token, secret)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if parsed.UserID != 3 || parsed.Role != "cashier" {
+		t.Errorf("unexpected claims %+v", parsed)
+	}
+
+	if _, err := ParseToken(token, []byte("other-secret")); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for wrong secret, got %v", err)
+	}
+
+	claims.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	expired, _ := SignToken(claims, secret)
+	if _, err := ParseToken(expired, secret); err != ErrExpiredToken {
+		t.Errorf("expected ErrExpiredToken, got %v", err)
+	}
+}
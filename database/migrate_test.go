@@ -0,0 +1,133 @@
+package database
+
+import (
+	"kasir-api/migrations"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLoadMigrations_SortsAndPairs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_b.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"002_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+		"README.md":      {Data: []byte("ignored")},
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "b" {
+		t.Fatalf("expected migrations 1 and 2 in order, got %+v", migrations)
+	}
+	if migrations[0].Down != "" || migrations[1].Down != "DROP TABLE b;" {
+		t.Errorf("down scripts not paired correctly: %+v", migrations)
+	}
+
+	if _, err := LoadMigrations(fstest.MapFS{"003_c.down.sql": {Data: []byte("DROP TABLE c;")}}); err == nil {
+		t.Error("expected error for migration without up file")
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i, m := range all {
+		if m.Version != i {
+			t.Errorf("expected version %d, got %03d_%s", i, m.Version, m.Name)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %03d_%s has no down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigratorUp_AppliesPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	migrator, err := NewMigrator(db, fstest.MapFS{
+		"001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+		"001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"002_b.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"002_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE b").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(2, "b", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("expected only migration 2 to be applied, got %+v", applied)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMigratorDown_RevertsNewestFirst(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	migrator, err := NewMigrator(db, fstest.MapFS{
+		"001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+		"001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"002_b.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"002_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP TABLE b").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	reverted, err := migrator.Down(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Errorf("expected migration 2 to be reverted, got %+v", reverted)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
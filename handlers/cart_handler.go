@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/middleware"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CartHandler struct {
+	service     *services.CartService
+	idempotency *middleware.Idempotency
+}
+
+// NewCartHandler takes the Idempotency-Key handling used for cart checkout, the
+// only cart action that needs it.
+func NewCartHandler(service *services.CartService, idempotency *middleware.Idempotency) *CartHandler {
+	return &CartHandler{service: service, idempotency: idempotency}
+}
+
+// HandleCarts - GET /api/carts?status=, POST /api/carts
+func (h *CartHandler) HandleCarts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		carts, err := h.service.GetAll(r.URL.Query().Get("status"))
+		if err != nil {
+			writeCartError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(carts)
+	case http.MethodPost:
+		var req models.CartRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		cart, err := h.service.Create(req, currentUserID(r))
+		if err != nil {
+			writeCartError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(cart)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleCartByID - GET/PUT/DELETE /api/carts/{id}, POST /api/carts/{id}/items,
+// PUT/DELETE /api/carts/{id}/items/{product_id}?unit=, POST /api/carts/{id}/park,
+// POST /api/carts/{id}/resume, POST /api/carts/{id}/checkout
+func (h *CartHandler) HandleCartByID(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/carts/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid cart ID", http.StatusBadRequest)
+		return
+	}
+
+	if action == "checkout" {
+		h.idempotency.Wrap(func(w http.ResponseWriter, r *http.Request) { h.checkout(w, r, id) })(w, r)
+		return
+	}
+	if productIDStr, ok := strings.CutPrefix(action, "items/"); ok {
+		productID, err := strconv.Atoi(productIDStr)
+		if err != nil {
+			http.Error(w, "Invalid product ID", http.StatusBadRequest)
+			return
+		}
+		h.handleItem(w, r, id, productID)
+		return
+	}
+
+	allowed := r.Method == http.MethodPost
+	if action == "" {
+		allowed = r.Method == http.MethodGet || r.Method == http.MethodPut || r.Method == http.MethodDelete
+	}
+	if !allowed {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var cart *models.Cart
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		cart, err = h.service.GetByID(id)
+	case action == "" && r.Method == http.MethodPut:
+		var req models.CartUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		cart, err = h.service.Update(id, req)
+	case action == "":
+		cart, err = h.service.Cancel(id)
+	case action == "items":
+		var req models.CartItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		cart, err = h.service.AddItem(id, req)
+	case action == "park":
+		cart, err = h.service.Park(id)
+	case action == "resume":
+		cart, err = h.service.Resume(id)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+// handleItem - PUT /api/carts/{id}/items/{product_id}?unit= {"quantity": n}, DELETE /api/carts/{id}/items/{product_id}?unit=
+// Without unit the line in the base unit is meant.
+func (h *CartHandler) handleItem(w http.ResponseWriter, r *http.Request, id, productID int) {
+	var quantity int
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Quantity int `json:"quantity"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		quantity = req.Quantity
+	case http.MethodDelete:
+		quantity = 0
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cart, err := h.service.SetItemQuantity(id, productID, r.URL.Query().Get("unit"), quantity)
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+// checkout - POST /api/carts/{id}/checkout?lock=true
+func (h *CartHandler) checkout(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CartCheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Same as a direct checkout: the cashier is whoever is logged in
+	if claims, ok := middleware.UserFromContext(r.Context()); ok {
+		req.CashierID = claims.UserID
+		req.CashierName = claims.Username
+	}
+	if req.RegisterID == "" {
+		req.RegisterID = r.Header.Get("X-Register-ID")
+	}
+
+	transaction, err := h.service.Checkout(id, req, r.URL.Query().Get("lock") == "true")
+	if err != nil {
+		writeCartError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
+func writeCartError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "cart id") && strings.Contains(msg, "not found"), strings.Contains(msg, "not found in cart"):
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.Contains(msg, "already"), strings.Contains(msg, "cannot become"), strings.Contains(msg, "only open carts"),
+		strings.Contains(msg, "has no items"), strings.Contains(msg, "no open shift"):
+		http.Error(w, msg, http.StatusConflict)
+	case strings.Contains(msg, "required"), strings.Contains(msg, "quantity"), strings.Contains(msg, "invalid"),
+		strings.Contains(msg, "payment"), strings.Contains(msg, "promo code"), strings.Contains(msg, "archived"),
+		strings.Contains(msg, "insufficient stock"), strings.Contains(msg, "register_id"), strings.Contains(msg, "not found"):
+		http.Error(w, msg, http.StatusBadRequest)
+	case strings.Contains(msg, "timed out"):
+		http.Error(w, msg, http.StatusServiceUnavailable)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
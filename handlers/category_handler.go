@@ -29,8 +29,9 @@ func (h *CategoryHandler) HandleCategories(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// GetAll - GET /categories?include_archived=true
 func (h *CategoryHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	categories, err := h.service.GetAll()
+	categories, err := h.service.GetAll(r.URL.Query().Get("include_archived") == "true")
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -49,6 +50,11 @@ func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if category.TaxRate != nil && (*category.TaxRate < 0 || *category.TaxRate > 100) {
+		http.Error(w, "Tax rate must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+
 	err = h.service.Create(&category)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -60,7 +66,26 @@ func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(category)
 }
 
+// HandleCategoryByID - GET/PUT/DELETE /categories/{id}, POST /categories/{id}/restore
 func (h *CategoryHandler) HandleCategoryByID(w http.ResponseWriter, r *http.Request) {
+	if idStr, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/categories/"), "/"); ok {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "Invalid category ID", http.StatusBadRequest)
+			return
+		}
+		if action != "restore" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Restore(w, r, id)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		h.GetByID(w, r)
@@ -111,6 +136,11 @@ func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if category.TaxRate != nil && (*category.TaxRate < 0 || *category.TaxRate > 100) {
+		http.Error(w, "Tax rate must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+
 	category.ID = id
 	err = h.service.Update(&category)
 	if err != nil {
@@ -145,3 +175,19 @@ func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		"message": "Category deleted successfully",
 	})
 }
+
+// Restore - POST /categories/{id}/restore
+func (h *CategoryHandler) Restore(w http.ResponseWriter, r *http.Request, id int) {
+	category, err := h.service.Restore(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak ditemukan") {
+			http.Error(w, "Category not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
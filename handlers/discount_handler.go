@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type DiscountHandler struct {
+	service *services.DiscountService
+}
+
+func NewDiscountHandler(service *services.DiscountService) *DiscountHandler {
+	return &DiscountHandler{service: service}
+}
+
+// HandleDiscounts - GET/POST /api/discounts
+func (h *DiscountHandler) HandleDiscounts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	case http.MethodPost:
+		h.Create(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *DiscountHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	discounts, err := h.service.GetAll()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(discounts)
+}
+
+func (h *DiscountHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var discount models.Discount
+	err := json.NewDecoder(r.Body).Decode(&discount)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.Create(&discount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(discount)
+}
+
+// HandleDiscountByID - GET/PUT/DELETE /api/discounts/{id}
+func (h *DiscountHandler) HandleDiscountByID(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetByID(w, r)
+	case http.MethodPut:
+		h.Update(w, r)
+	case http.MethodDelete:
+		h.Delete(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *DiscountHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/discounts/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid discount ID", http.StatusBadRequest)
+		return
+	}
+
+	discount, err := h.service.GetByID(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak ditemukan") {
+			http.Error(w, "Discount not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(discount)
+}
+
+func (h *DiscountHandler) Update(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/discounts/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid discount ID", http.StatusBadRequest)
+		return
+	}
+
+	var discount models.Discount
+	err = json.NewDecoder(r.Body).Decode(&discount)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON format: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	discount.ID = id
+	err = h.service.Update(&discount)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak ditemukan") {
+			http.Error(w, "Discount not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(discount)
+}
+
+func (h *DiscountHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/discounts/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid discount ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.Delete(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak ditemukan") {
+			http.Error(w, "Discount not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Discount deleted successfully",
+	})
+}
@@ -18,7 +18,7 @@ func NewProductHandler(service *services.ProductService) *ProductHandler {
 	return &ProductHandler{service: service}
 }
 
-// HandleProducts - GET /api/produk
+// HandleProducts - GET /api/produk?name=&include_archived=true, POST /api/produk
 func (h *ProductHandler) HandleProducts(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -32,8 +32,9 @@ func (h *ProductHandler) HandleProducts(w http.ResponseWriter, r *http.Request)
 
 func (h *ProductHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
+	includeArchived := r.URL.Query().Get("include_archived") == "true"
 
-	products, err := h.service.GetAll(name)
+	products, err := h.service.GetAll(name, includeArchived)
 	if err != nil {
 		// Log error in real app
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -62,7 +63,7 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.service.Create(&product)
+	err = h.service.Create(&product, currentUserID(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -73,8 +74,60 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(product)
 }
 
-// HandleProductByID - GET/PUT/DELETE /api/produk/{id}
+// HandleProductByID - GET/PUT/DELETE /api/produk/{id}, GET /api/produk/{id}/stock-history,
+// POST /api/produk/{id}/stock, POST /api/produk/{id}/restore, GET /api/produk/low-stock,
+// GET /api/produk/barcode/{code}
 func (h *ProductHandler) HandleProductByID(w http.ResponseWriter, r *http.Request) {
+	if code, ok := strings.CutPrefix(r.URL.Path, "/api/produk/barcode/"); ok {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.GetByBarcode(w, r, code)
+		return
+	}
+
+	if r.URL.Path == "/api/produk/low-stock" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.LowStock(w, r)
+		return
+	}
+
+	if idStr, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/produk/"), "/"); ok {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "Invalid product ID", http.StatusBadRequest)
+			return
+		}
+
+		switch action {
+		case "stock-history":
+			if r.Method != http.MethodGet {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.StockHistory(w, r, id)
+		case "stock":
+			if r.Method != http.MethodPost {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.AdjustStock(w, r, id)
+		case "restore":
+			if r.Method != http.MethodPost {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			h.Restore(w, r, id)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		h.GetByID(w, r)
@@ -133,15 +186,11 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate input
+	// Validate input; stock is not editable here, use POST /api/produk/{id}/stock
 	if product.Price < 0 {
 		http.Error(w, "Price cannot be negative", http.StatusBadRequest)
 		return
 	}
-	if product.Stock < 0 {
-		http.Error(w, "Stock cannot be negative", http.StatusBadRequest)
-		return
-	}
 
 	// Debug: Print decoded product
 	fmt.Printf("Decoded Product: %+v\n", product)
@@ -161,7 +210,7 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(product)
 }
 
-// Delete - DELETE /api/produk/{id}
+// Delete - DELETE /api/produk/{id}, archives the product
 func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	idStr := strings.TrimPrefix(r.URL.Path, "/api/produk/")
 	id, err := strconv.Atoi(idStr)
@@ -185,3 +234,97 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		"message": "Product deleted successfully",
 	})
 }
+
+// Restore - POST /api/produk/{id}/restore
+func (h *ProductHandler) Restore(w http.ResponseWriter, r *http.Request, id int) {
+	product, err := h.service.Restore(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak ditemukan") {
+			http.Error(w, "Product not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(product)
+}
+
+// StockHistory - GET /api/produk/{id}/stock-history?page=&limit=
+func (h *ProductHandler) StockHistory(w http.ResponseWriter, r *http.Request, id int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	history, err := h.service.StockHistory(id, page, limit)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak ditemukan") {
+			http.Error(w, "Product not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// AdjustStock - POST /api/produk/{id}/stock
+func (h *ProductHandler) AdjustStock(w http.ResponseWriter, r *http.Request, id int) {
+	var req models.StockAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	movement, err := h.service.AdjustStock(id, req, currentUserID(r))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "tidak ditemukan"):
+			http.Error(w, "Product not found", http.StatusNotFound)
+		case strings.Contains(err.Error(), "below zero"):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case strings.Contains(err.Error(), "quantity"), strings.Contains(err.Error(), "reason"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(movement)
+}
+
+// GetByBarcode - GET /api/produk/barcode/{code}
+func (h *ProductHandler) GetByBarcode(w http.ResponseWriter, r *http.Request, code string) {
+	product, err := h.service.GetByBarcode(code)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "tidak ditemukan"):
+			http.Error(w, "Product not found", http.StatusNotFound)
+		case strings.Contains(err.Error(), "invalid barcode"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(product)
+}
+
+// LowStock - GET /api/produk/low-stock
+func (h *ProductHandler) LowStock(w http.ResponseWriter, r *http.Request) {
+	products, err := h.service.GetLowStock()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(products)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type PurchaseOrderHandler struct {
+	service *services.PurchaseOrderService
+}
+
+func NewPurchaseOrderHandler(service *services.PurchaseOrderService) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{service: service}
+}
+
+// HandlePurchaseOrders - GET /api/purchase-orders?status=&supplier_id=, POST /api/purchase-orders
+func (h *PurchaseOrderHandler) HandlePurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		filter := models.PurchaseOrderFilter{Status: r.URL.Query().Get("status")}
+		if v := r.URL.Query().Get("supplier_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, "Invalid supplier_id", http.StatusBadRequest)
+				return
+			}
+			filter.SupplierID = id
+		}
+
+		orders, err := h.service.GetAll(filter)
+		if err != nil {
+			writePurchaseOrderError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(orders)
+	case http.MethodPost:
+		var req models.PurchaseOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.CreatedBy = currentUserID(r)
+
+		order, err := h.service.Create(req)
+		if err != nil {
+			writePurchaseOrderError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(order)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandlePurchaseOrderByID - GET/PUT /api/purchase-orders/{id}, POST /api/purchase-orders/{id}/order,
+// POST /api/purchase-orders/{id}/cancel, POST /api/purchase-orders/{id}/receive
+func (h *PurchaseOrderHandler) HandlePurchaseOrderByID(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/purchase-orders/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid purchase order ID", http.StatusBadRequest)
+		return
+	}
+
+	allowed := r.Method == http.MethodPost
+	if action == "" {
+		allowed = r.Method == http.MethodGet || r.Method == http.MethodPut
+	}
+	if !allowed {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var order *models.PurchaseOrder
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		order, err = h.service.GetByID(id)
+	case action == "":
+		var req models.PurchaseOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		order, err = h.service.Update(id, req)
+	case action == "order":
+		order, err = h.service.Order(id)
+	case action == "cancel":
+		order, err = h.service.Cancel(id)
+	case action == "receive":
+		var req models.ReceiveGoodsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.ReceivedBy = currentUserID(r)
+		order, err = h.service.Receive(id, req)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writePurchaseOrderError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+func writePurchaseOrderError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "purchase order id") && strings.Contains(msg, "not found"):
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.Contains(msg, "already"), strings.Contains(msg, "only drafts"), strings.Contains(msg, "can only be received"):
+		http.Error(w, msg, http.StatusConflict)
+	case strings.Contains(msg, "required"), strings.Contains(msg, "negative"), strings.Contains(msg, "greater than 0"),
+		strings.Contains(msg, "not found"), strings.Contains(msg, "listed twice"), strings.Contains(msg, "not on purchase order"),
+		strings.Contains(msg, "outstanding"), strings.Contains(msg, "invalid status"):
+		http.Error(w, msg, http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"kasir-api/services"
 	"net/http"
+	"strings"
 )
 
 type ReportHandler struct {
@@ -20,8 +21,12 @@ func (h *ReportHandler) HandleDailyReport(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	summary, err := h.service.GetDailyReport()
+	summary, err := h.service.GetDailyReport(r.URL.Query().Get("group_by"))
 	if err != nil {
+		if strings.Contains(err.Error(), "invalid group_by") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		// Log error
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -47,7 +52,7 @@ func (h *ReportHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	summary, err := h.service.GetReport(startDate, endDate)
+	summary, err := h.service.GetReport(startDate, endDate, r.URL.Query().Get("group_by"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -56,3 +61,31 @@ func (h *ReportHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(summary)
 }
+
+// HandleMarginReport - GET /api/report/margin?start_date=&end_date=&group_by=product|category|day
+func (h *ReportHandler) HandleMarginReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	startDate := r.URL.Query().Get("start_date")
+	endDate := r.URL.Query().Get("end_date")
+	if startDate == "" || endDate == "" {
+		http.Error(w, "start_date and end_date are required", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.GetMarginReport(startDate, endDate, r.URL.Query().Get("group_by"))
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/middleware"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type ShiftHandler struct {
+	service *services.ShiftService
+}
+
+func NewShiftHandler(service *services.ShiftService) *ShiftHandler {
+	return &ShiftHandler{service: service}
+}
+
+// HandleShifts - GET /api/shifts?status=open|closed
+func (h *ShiftHandler) HandleShifts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shifts, err := h.service.GetAll(r.URL.Query().Get("status"))
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid status") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shifts)
+}
+
+// HandleShiftByID - POST /api/shifts/open, GET /api/shifts/current,
+// GET /api/shifts/{id}/report, POST /api/shifts/{id}/close
+func (h *ShiftHandler) HandleShiftByID(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/shifts/"), "/")
+	switch idStr {
+	case "open":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Open(w, r, claims.UserID)
+		return
+	case "current":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Current(w, r, claims.UserID)
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid shift ID", http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case "", "report":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		report, err := h.service.Report(id, claims.UserID, claims.Role)
+		if err != nil {
+			writeShiftError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	case "close":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Close(w, r, id, claims.UserID, claims.Role)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Open - POST /api/shifts/open
+func (h *ShiftHandler) Open(w http.ResponseWriter, r *http.Request, cashierID int) {
+	var req models.OpenShiftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	shift, err := h.service.Open(cashierID, req)
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shift)
+}
+
+// Current - GET /api/shifts/current
+func (h *ShiftHandler) Current(w http.ResponseWriter, r *http.Request, cashierID int) {
+	shift, err := h.service.GetCurrent(cashierID)
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shift)
+}
+
+// Close - POST /api/shifts/{id}/close
+func (h *ShiftHandler) Close(w http.ResponseWriter, r *http.Request, id, userID int, role string) {
+	var req models.CloseShiftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.Close(id, userID, role, req)
+	if err != nil {
+		writeShiftError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func writeShiftError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "no open shift"):
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.Contains(msg, "another cashier"):
+		http.Error(w, msg, http.StatusForbidden)
+	case strings.Contains(msg, "already"), strings.Contains(msg, "duplicate key"):
+		http.Error(w, msg, http.StatusConflict)
+	case strings.Contains(msg, "required"), strings.Contains(msg, "negative"):
+		http.Error(w, msg, http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
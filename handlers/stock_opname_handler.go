@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kasir-api/models"
+	"kasir-api/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type StockOpnameHandler struct {
+	service *services.StockOpnameService
+}
+
+func NewStockOpnameHandler(service *services.StockOpnameService) *StockOpnameHandler {
+	return &StockOpnameHandler{service: service}
+}
+
+// HandleStockOpnames - GET/POST /api/stock-opname
+func (h *StockOpnameHandler) HandleStockOpnames(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		opnames, err := h.service.GetAll(r.URL.Query().Get("status"))
+		if err != nil {
+			writeStockOpnameError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(opnames)
+	case http.MethodPost:
+		var req models.StartStockOpnameRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		opname, err := h.service.Start(req, currentUserID(r))
+		if err != nil {
+			writeStockOpnameError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(opname)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleStockOpnameByID - GET /api/stock-opname/{id}, POST /api/stock-opname/{id}/counts,
+// POST /api/stock-opname/{id}/commit, POST /api/stock-opname/{id}/cancel
+func (h *StockOpnameHandler) HandleStockOpnameByID(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/stock-opname/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid stock opname ID", http.StatusBadRequest)
+		return
+	}
+
+	wantMethod := http.MethodPost
+	if action == "" {
+		wantMethod = http.MethodGet
+	}
+	if r.Method != wantMethod {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var result any
+	switch action {
+	case "":
+		result, err = h.service.Report(id)
+	case "counts":
+		var req models.StockOpnameCountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		result, err = h.service.SubmitCounts(id, req)
+	case "commit":
+		result, err = h.service.Commit(id, currentUserID(r))
+	case "cancel":
+		result, err = h.service.Cancel(id)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeStockOpnameError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeStockOpnameError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.Contains(msg, "already"), strings.Contains(msg, "duplicate key"), strings.Contains(msg, "no counted items"), strings.Contains(msg, "count it again"):
+		http.Error(w, msg, http.StatusConflict)
+	case strings.Contains(msg, "required"), strings.Contains(msg, "negative"), strings.Contains(msg, "invalid status"):
+		http.Error(w, msg, http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
@@ -2,19 +2,25 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
+	"kasir-api/middleware"
 	"kasir-api/models"
 	"kasir-api/services"
 	"strings"
 )
 
 type TransactionHandler struct {
-	service *services.TransactionService
+	service  *services.TransactionService
+	receipts *services.ReceiptService
 }
 
-func NewTransactionHandler(service *services.TransactionService) *TransactionHandler {
-	return &TransactionHandler{service: service}
+func NewTransactionHandler(service *services.TransactionService, receipts *services.ReceiptService) *TransactionHandler {
+	return &TransactionHandler{service: service, receipts: receipts}
 }
 
 // multiple item apa aja, quantity nya
@@ -43,6 +49,15 @@ func (h *TransactionHandler) Checkout(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// The cashier is whoever is logged in; the register, if sent, must match their open shift
+	if claims, ok := middleware.UserFromContext(r.Context()); ok {
+		req.CashierID = claims.UserID
+		req.CashierName = claims.Username
+	}
+	if req.RegisterID == "" {
+		req.RegisterID = r.Header.Get("X-Register-ID")
+	}
+
 	// default to false if not provided (or we can get from query params if needed)
 	useLock := false
 	// optional: get useLock from query params, e.g., ?lock=true
@@ -50,13 +65,27 @@ func (h *TransactionHandler) Checkout(w http.ResponseWriter, r *http.Request) {
 		useLock = true
 	}
 
-	transaction, err := h.service.Checkout(req.Items, useLock)
+	transaction, err := h.service.Checkout(req, useLock)
 	if err != nil {
 		// Start with specific error checks
-		if strings.Contains(err.Error(), "insufficient stock") {
+		if strings.Contains(err.Error(), "insufficient stock") || strings.Contains(err.Error(), "archived") {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if strings.Contains(err.Error(), "payment") || strings.Contains(err.Error(), "promo code") || strings.Contains(err.Error(), "register_id") ||
+			strings.Contains(err.Error(), "at least one item") ||
+			strings.Contains(err.Error(), "invalid barcode") || strings.Contains(err.Error(), "invalid unit") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(err.Error(), "timed out") {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if strings.Contains(err.Error(), "no open shift") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
@@ -70,3 +99,221 @@ func (h *TransactionHandler) Checkout(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(transaction)
 }
+
+// HandleTransactions - GET /api/transactions
+func (h *TransactionHandler) HandleTransactions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAll(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleTransactionByID - GET /api/transactions/{id}, POST /api/transactions/{id}/void,
+// POST /api/transactions/{id}/refund, GET /api/transactions/{id}/receipt
+func (h *TransactionHandler) HandleTransactionByID(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/transactions/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.GetByID(w, r, id)
+	case "void":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Void(w, r, id)
+	case "refund":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Refund(w, r, id)
+	case "receipt":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.Receipt(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *TransactionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTransactionFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.service.GetTransactions(filter)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (h *TransactionHandler) GetByID(w http.ResponseWriter, r *http.Request, id int) {
+	transaction, err := h.service.GetTransactionByID(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
+// Void - POST /api/transactions/{id}/void
+func (h *TransactionHandler) Void(w http.ResponseWriter, r *http.Request, id int) {
+	var req models.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = currentUserID(r)
+
+	refund, err := h.service.Void(id, req)
+	if err != nil {
+		writeRefundError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refund)
+}
+
+// Refund - POST /api/transactions/{id}/refund
+func (h *TransactionHandler) Refund(w http.ResponseWriter, r *http.Request, id int) {
+	var req models.RefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.UserID = currentUserID(r)
+
+	refund, err := h.service.Refund(id, req)
+	if err != nil {
+		writeRefundError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refund)
+}
+
+// Receipt - GET /api/transactions/{id}/receipt?format=text|escpos|pdf
+func (h *TransactionHandler) Receipt(w http.ResponseWriter, r *http.Request, id int) {
+	format := r.URL.Query().Get("format")
+
+	content, contentType, err := h.receipts.Render(id, format)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+		} else if strings.Contains(err.Error(), "invalid receipt format") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if format == "pdf" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"struk-%d.pdf\"", id))
+	}
+	w.Write(content)
+}
+
+func writeRefundError(w http.ResponseWriter, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		http.Error(w, msg, http.StatusNotFound)
+	case strings.Contains(msg, "already"), strings.Contains(msg, "nothing left"):
+		http.Error(w, msg, http.StatusConflict)
+	case strings.Contains(msg, "required"), strings.Contains(msg, "quantity"):
+		http.Error(w, msg, http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// parseTransactionFilter reads ?start_date, end_date (YYYY-MM-DD), product_id,
+// cashier_id, register_id, min_amount, max_amount, page and limit from the query string.
+func parseTransactionFilter(query url.Values) (models.TransactionFilter, error) {
+	var filter models.TransactionFilter
+	layout := "2006-01-02"
+
+	if v := query.Get("start_date"); v != "" {
+		startDate, err := time.ParseInLocation(layout, v, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("invalid start_date format (YYYY-MM-DD)")
+		}
+		filter.StartDate = &startDate
+	}
+	if v := query.Get("end_date"); v != "" {
+		endDate, err := time.ParseInLocation(layout, v, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("invalid end_date format (YYYY-MM-DD)")
+		}
+		// Include the full end day
+		endDate = endDate.Add(24 * time.Hour)
+		filter.EndDate = &endDate
+	}
+
+	ints := map[string]*int{
+		"product_id": &filter.ProductID,
+		"cashier_id": &filter.CashierID,
+		"page":       &filter.Page,
+		"limit":      &filter.Limit,
+	}
+	for key, target := range ints {
+		if v := query.Get(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("invalid %s", key)
+			}
+			*target = n
+		}
+	}
+
+	filter.RegisterID = query.Get("register_id")
+
+	if v := query.Get("min_amount"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid min_amount")
+		}
+		filter.MinAmount = &n
+	}
+	if v := query.Get("max_amount"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid max_amount")
+		}
+		filter.MaxAmount = &n
+	}
+
+	return filter, nil
+}
@@ -1,19 +1,24 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"kasir-api/alerts"
 	"kasir-api/database"
 	"kasir-api/handlers"
 	"kasir-api/middleware"
 	"kasir-api/models"
+	"kasir-api/receipt"
 	"kasir-api/repositories"
 	"kasir-api/services"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -21,6 +26,25 @@ import (
 type Config struct {
 	PORT   string `mapstructure:"PORT"`
 	DBConn string `mapstructure:"DB_CONN"`
+
+	TaxRate           float64 `mapstructure:"TAX_RATE"`
+	TaxInclusive      bool    `mapstructure:"TAX_INCLUSIVE"`
+	ServiceChargeRate float64 `mapstructure:"SERVICE_CHARGE_RATE"`
+
+	ReceiptStoreName string `mapstructure:"RECEIPT_STORE_NAME"`
+	ReceiptHeader    string `mapstructure:"RECEIPT_HEADER"`
+	ReceiptFooter    string `mapstructure:"RECEIPT_FOOTER"`
+	ReceiptWidth     int    `mapstructure:"RECEIPT_WIDTH"`
+	ReceiptTemplate  string `mapstructure:"RECEIPT_TEMPLATE"`
+
+	JWTSecret     string        `mapstructure:"JWT_SECRET"`
+	TokenTTL      time.Duration `mapstructure:"TOKEN_TTL"`
+	AdminUsername string        `mapstructure:"ADMIN_USERNAME"`
+	AdminPassword string        `mapstructure:"ADMIN_PASSWORD"`
+
+	LowStockWebhookURL string `mapstructure:"LOW_STOCK_WEBHOOK_URL"`
+
+	StockReservationTTL time.Duration `mapstructure:"STOCK_RESERVATION_TTL"`
 }
 
 var db *sql.DB
@@ -37,81 +61,50 @@ func loadConfig() Config {
 	config := Config{
 		PORT:   viper.GetString("PORT"),
 		DBConn: viper.GetString("DB_CONN"),
-	}
-	return config
-}
 
-func createTablesAndData() {
-	if db == nil {
-		return
-	}
+		TaxRate:           viper.GetFloat64("TAX_RATE"),
+		TaxInclusive:      viper.GetBool("TAX_INCLUSIVE"),
+		ServiceChargeRate: viper.GetFloat64("SERVICE_CHARGE_RATE"),
 
-	// Create tables
-	categoryTable := `
-	CREATE TABLE IF NOT EXISTS categories (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(100) NOT NULL,
-		description TEXT
-	);`
-
-	productTable := `
-	CREATE TABLE IF NOT EXISTS products (
-		id SERIAL PRIMARY KEY,
-		name VARCHAR(100) NOT NULL,
-		price INTEGER NOT NULL,
-		stock INTEGER NOT NULL,
-		category_id INTEGER REFERENCES categories(id)
-	);`
-
-	transactionTable := `
-	CREATE TABLE IF NOT EXISTS transactions (
-		id SERIAL PRIMARY KEY,
-		total_amount INTEGER NOT NULL,
-		created_at TIMESTAMP NOT NULL
-	);`
-
-	transactionDetailTable := `
-	CREATE TABLE IF NOT EXISTS transaction_details (
-		id SERIAL PRIMARY KEY,
-		transaction_id INTEGER NOT NULL REFERENCES transactions(id),
-		product_id INTEGER NOT NULL REFERENCES products(id),
-		quantity INTEGER NOT NULL,
-		subtotal INTEGER NOT NULL
-	);`
-
-	_, err := db.Exec(categoryTable)
-	if err != nil {
-		fmt.Printf("Failed to create categories table: %v\n", err)
-		return
-	}
+		ReceiptStoreName: viper.GetString("RECEIPT_STORE_NAME"),
+		ReceiptHeader:    viper.GetString("RECEIPT_HEADER"),
+		ReceiptFooter:    viper.GetString("RECEIPT_FOOTER"),
+		ReceiptWidth:     viper.GetInt("RECEIPT_WIDTH"),
+		ReceiptTemplate:  viper.GetString("RECEIPT_TEMPLATE"),
 
-	_, err = db.Exec(productTable)
-	if err != nil {
-		fmt.Printf("Failed to create products table: %v\n", err)
-		return
-	}
+		JWTSecret:     viper.GetString("JWT_SECRET"),
+		TokenTTL:      viper.GetDuration("TOKEN_TTL"),
+		AdminUsername: viper.GetString("ADMIN_USERNAME"),
+		AdminPassword: viper.GetString("ADMIN_PASSWORD"),
 
-	_, err = db.Exec(transactionTable)
-	if err != nil {
-		fmt.Printf("Failed to create transactions table: %v\n", err)
-		return
-	}
+		LowStockWebhookURL: viper.GetString("LOW_STOCK_WEBHOOK_URL"),
 
-	_, err = db.Exec(transactionDetailTable)
-	if err != nil {
-		fmt.Printf("Failed to create transaction details table: %v\n", err)
-		return
+		StockReservationTTL: viper.GetDuration("STOCK_RESERVATION_TTL"),
+	}
+	if config.ReceiptStoreName == "" {
+		config.ReceiptStoreName = "Kasir API"
+	}
+	if config.ReceiptFooter == "" {
+		config.ReceiptFooter = "Terima kasih atas kunjungan Anda"
 	}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = 12 * time.Hour
+	}
+	if config.StockReservationTTL <= 0 {
+		config.StockReservationTTL = 30 * time.Minute
+	}
+	if config.AdminUsername == "" {
+		config.AdminUsername = "admin"
+	}
+	return config
+}
 
-	// Add category_id column if not exists
-	_, err = db.Exec("ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories(id)")
-	if err != nil {
-		fmt.Printf("Failed to add category_id column: %v\n", err)
+// seedSampleData fills an empty database with a few categories and products.
+func seedSampleData() {
+	if db == nil {
 		return
 	}
 
-	fmt.Println("Database tables created successfully")
-
 	// Insert sample data
 	var count int
 	db.QueryRow("SELECT COUNT(*) FROM products").Scan(&count)
@@ -151,9 +144,36 @@ func createTablesAndData() {
 	}
 }
 
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "|")
+}
+
+// jwtSecret returns the configured signing secret. Without one a random secret is
+// used, so tokens stop working whenever the server restarts.
+func jwtSecret(config Config) []byte {
+	if config.JWTSecret != "" {
+		return []byte(config.JWTSecret)
+	}
+
+	fmt.Println("Warning: JWT_SECRET is not set, using a random secret for this run")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal("Failed to generate JWT secret:", err)
+	}
+	return secret
+}
+
 func main() {
 	config := loadConfig()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(config, os.Args[2:])
+		return
+	}
+
 	// Debug: Print loaded config
 	fmt.Printf("Loaded config - PORT: %s, DB_CONN: %s\n", config.PORT, config.DBConn)
 
@@ -166,40 +186,153 @@ func main() {
 		}
 		defer db.Close()
 
-		// Create tables and insert sample data
-		createTablesAndData()
+		// Apply pending schema migrations and insert sample data
+		if err := migrateUp(0); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+		seedSampleData()
 	} else {
 		fmt.Println("No database connection configured, running without database")
 	}
 
 	// Dependency Injection
 	productRepo := repositories.NewProductRepository(db)
-	productService := services.NewProductService(productRepo)
+	stockRepo := repositories.NewStockRepository(db)
+	productService := services.NewProductService(productRepo, stockRepo)
 	productHandler := handlers.NewProductHandler(productService)
 
 	categoryRepo := repositories.NewCategoryRepository(db)
 	categoryService := services.NewCategoryService(categoryRepo)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
 
-	transactionRepo := repositories.NewTransactionRepository(db)
-	transactionService := services.NewTransactionService(transactionRepo)
-	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	discountRepo := repositories.NewDiscountRepository(db)
+	discountService := services.NewDiscountService(discountRepo)
+	discountHandler := handlers.NewDiscountHandler(discountService)
+
+	taxConfig := models.TaxConfig{
+		Rate:              config.TaxRate,
+		Inclusive:         config.TaxInclusive,
+		ServiceChargeRate: config.ServiceChargeRate,
+	}
+	transactionRepo := repositories.NewTransactionRepository(db, taxConfig)
+	// Low-stock alerts go to the webhook when one is configured, otherwise to the log
+	var lowStockNotifier alerts.Notifier = alerts.LogNotifier{}
+	if config.LowStockWebhookURL != "" {
+		lowStockNotifier = alerts.NewWebhookNotifier(config.LowStockWebhookURL)
+	}
+	transactionService := services.NewTransactionService(transactionRepo, lowStockNotifier)
+
+	// Header and footer lines are separated by "|" in the environment
+	receiptRenderer, err := receipt.NewRenderer(receipt.Config{
+		StoreName:    config.ReceiptStoreName,
+		Header:       splitLines(config.ReceiptHeader),
+		Footer:       splitLines(config.ReceiptFooter),
+		Width:        config.ReceiptWidth,
+		TemplatePath: config.ReceiptTemplate,
+	})
+	if err != nil {
+		log.Fatal("Failed to load receipt template:", err)
+	}
+	receiptService := services.NewReceiptService(transactionRepo, receiptRenderer)
+	transactionHandler := handlers.NewTransactionHandler(transactionService, receiptService)
 	reportHandler := handlers.NewReportHandler(transactionService)
 
+	// Retried checkouts with the same Idempotency-Key replay the first response
+	idempotency := middleware.NewIdempotency(repositories.NewIdempotencyRepository(db))
+
+	// Carts are priced with the same tax settings as checkout
+	cartRepo := repositories.NewCartRepository(db, taxConfig)
+	cartService := services.NewCartService(cartRepo, transactionService, config.StockReservationTTL)
+	cartHandler := handlers.NewCartHandler(cartService, idempotency)
+	// Stock held for parked carts stops counting once it expires; the worker clears it out
+	if db != nil {
+		go cartService.ExpireReservations(context.Background(), time.Minute)
+	}
+
+	shiftRepo := repositories.NewShiftRepository(db)
+	shiftService := services.NewShiftService(shiftRepo)
+	shiftHandler := handlers.NewShiftHandler(shiftService)
+
+	stockOpnameRepo := repositories.NewStockOpnameRepository(db)
+	stockOpnameService := services.NewStockOpnameService(stockOpnameRepo)
+	stockOpnameHandler := handlers.NewStockOpnameHandler(stockOpnameService)
+
+	supplierRepo := repositories.NewSupplierRepository(db)
+	supplierService := services.NewSupplierService(supplierRepo)
+	supplierHandler := handlers.NewSupplierHandler(supplierService)
+
+	purchaseOrderRepo := repositories.NewPurchaseOrderRepository(db)
+	purchaseOrderService := services.NewPurchaseOrderService(purchaseOrderRepo)
+	purchaseOrderHandler := handlers.NewPurchaseOrderHandler(purchaseOrderService)
+
+	secret := jwtSecret(config)
+	userRepo := repositories.NewUserRepository(db)
+	authService := services.NewAuthService(userRepo, secret, config.TokenTTL)
+	authHandler := handlers.NewAuthHandler(authService)
+	authenticator := middleware.NewAuthenticator(secret)
+
+	// The first admin comes from ADMIN_USERNAME/ADMIN_PASSWORD and is only created
+	// while the users table is empty
+	if db != nil {
+		if config.AdminPassword == "" {
+			fmt.Println("ADMIN_PASSWORD is not set, skipping initial admin user")
+		} else if created, err := authService.EnsureAdmin(config.AdminUsername, config.AdminPassword); err != nil {
+			fmt.Printf("Failed to create initial admin user: %v\n", err)
+		} else if created {
+			fmt.Printf("Initial admin user %q created\n", config.AdminUsername)
+		}
+	}
+
+	// Route policies: every role can read, only admins can change master data,
+	// void/refund transactions and see reports
+	anyRole := []string{models.RoleAdmin, models.RoleCashier}
+	adminOnly := []string{models.RoleAdmin}
+	readAnyWriteAdmin := middleware.Roles{Read: anyRole, Write: adminOnly}
+
 	// Category routes with dependency injection
-	http.HandleFunc("/categories/", categoryHandler.HandleCategoryByID)
-	http.HandleFunc("/categories", categoryHandler.HandleCategories)
+	http.HandleFunc("/categories/", authenticator.Require(readAnyWriteAdmin, categoryHandler.HandleCategoryByID))
+	http.HandleFunc("/categories", authenticator.Require(readAnyWriteAdmin, categoryHandler.HandleCategories))
 
 	// Product routes with dependency injection
-	http.HandleFunc("/api/produk/", productHandler.HandleProductByID)
-	http.HandleFunc("/api/produk", productHandler.HandleProducts)
+	http.HandleFunc("/api/produk/", authenticator.Require(readAnyWriteAdmin, productHandler.HandleProductByID))
+	http.HandleFunc("/api/produk", authenticator.Require(readAnyWriteAdmin, productHandler.HandleProducts))
+
+	// Discount routes
+	http.HandleFunc("/api/discounts/", authenticator.Require(readAnyWriteAdmin, discountHandler.HandleDiscountByID))
+	http.HandleFunc("/api/discounts", authenticator.Require(readAnyWriteAdmin, discountHandler.HandleDiscounts))
 
 	// Transaction routes with dependency injection
-	http.HandleFunc("/api/checkout", transactionHandler.HandleCheckout)
+	// Void and refund are the only non-GET actions under /api/transactions/{id}
+	http.HandleFunc("/api/checkout", authenticator.Require(middleware.Roles{Write: anyRole}, idempotency.Wrap(transactionHandler.HandleCheckout)))
+	// Any cashier can hold carts; cart checkout takes an Idempotency-Key like /api/checkout
+	http.HandleFunc("/api/carts/", authenticator.Require(middleware.Roles{Read: anyRole, Write: anyRole}, cartHandler.HandleCartByID))
+	http.HandleFunc("/api/carts", authenticator.Require(middleware.Roles{Read: anyRole, Write: anyRole}, cartHandler.HandleCarts))
+	http.HandleFunc("/api/transactions/", authenticator.Require(readAnyWriteAdmin, transactionHandler.HandleTransactionByID))
+	http.HandleFunc("/api/transactions", authenticator.Require(middleware.Roles{Read: anyRole}, transactionHandler.HandleTransactions))
 
 	// Report routes
-	http.HandleFunc("/api/report/hari-ini", reportHandler.HandleDailyReport)
-	http.HandleFunc("/api/report", reportHandler.HandleReport)
+	http.HandleFunc("/api/report/margin", authenticator.Require(middleware.Roles{Read: adminOnly}, reportHandler.HandleMarginReport))
+	http.HandleFunc("/api/report/hari-ini", authenticator.Require(middleware.Roles{Read: adminOnly}, reportHandler.HandleDailyReport))
+	http.HandleFunc("/api/report", authenticator.Require(middleware.Roles{Read: adminOnly}, reportHandler.HandleReport))
+
+	// Shift routes; cashiers can only report on and close their own shifts
+	http.HandleFunc("/api/shifts/", authenticator.Require(middleware.Roles{Read: anyRole, Write: anyRole}, shiftHandler.HandleShiftByID))
+	http.HandleFunc("/api/shifts", authenticator.Require(middleware.Roles{Read: adminOnly}, shiftHandler.HandleShifts))
+
+	// Stock opname routes
+	http.HandleFunc("/api/stock-opname/", authenticator.Require(middleware.Roles{Read: adminOnly, Write: adminOnly}, stockOpnameHandler.HandleStockOpnameByID))
+	http.HandleFunc("/api/stock-opname", authenticator.Require(middleware.Roles{Read: adminOnly, Write: adminOnly}, stockOpnameHandler.HandleStockOpnames))
+
+	// Purchasing routes
+	http.HandleFunc("/api/suppliers/", authenticator.Require(middleware.Roles{Read: adminOnly, Write: adminOnly}, supplierHandler.HandleSupplierByID))
+	http.HandleFunc("/api/suppliers", authenticator.Require(middleware.Roles{Read: adminOnly, Write: adminOnly}, supplierHandler.HandleSuppliers))
+	http.HandleFunc("/api/purchase-orders/", authenticator.Require(middleware.Roles{Read: adminOnly, Write: adminOnly}, purchaseOrderHandler.HandlePurchaseOrderByID))
+	http.HandleFunc("/api/purchase-orders", authenticator.Require(middleware.Roles{Read: adminOnly, Write: adminOnly}, purchaseOrderHandler.HandlePurchaseOrders))
+
+	// Auth and user management routes
+	http.HandleFunc("/api/auth/login", authHandler.HandleLogin)
+	http.HandleFunc("/api/auth/me", authenticator.Require(middleware.Roles{Read: anyRole}, authHandler.HandleMe))
+	http.HandleFunc("/api/users", authenticator.Require(middleware.Roles{Read: adminOnly, Write: adminOnly}, authHandler.HandleUsers))
 
 	// Root endpoint
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -212,17 +345,71 @@ func main() {
 			},
 			"endpoints": []string{
 				"GET /health",
+				"POST /api/auth/login",
+				"GET /api/auth/me",
+				"GET /api/users",
+				"POST /api/users",
 				"GET /api/produk",
 				"POST /api/produk",
 				"GET /api/produk/{id}",
+				"GET /api/produk/low-stock",
+				"GET /api/produk/barcode/{code}",
 				"PUT /api/produk/{id}",
 				"DELETE /api/produk/{id}",
+				"GET /api/produk/{id}/stock-history",
+				"POST /api/produk/{id}/stock",
+				"POST /api/produk/{id}/restore",
 				"GET /categories",
 				"POST /categories",
 				"GET /categories/{id}",
 				"PUT /categories/{id}",
 				"DELETE /categories/{id}",
+				"POST /categories/{id}/restore",
+				"GET /api/discounts",
+				"POST /api/discounts",
+				"GET /api/discounts/{id}",
+				"PUT /api/discounts/{id}",
+				"DELETE /api/discounts/{id}",
 				"POST /api/checkout",
+				"GET /api/carts",
+				"POST /api/carts",
+				"GET /api/carts/{id}",
+				"PUT /api/carts/{id}",
+				"DELETE /api/carts/{id}",
+				"POST /api/carts/{id}/items",
+				"PUT /api/carts/{id}/items/{product_id}",
+				"DELETE /api/carts/{id}/items/{product_id}",
+				"POST /api/carts/{id}/park",
+				"POST /api/carts/{id}/resume",
+				"POST /api/carts/{id}/checkout",
+				"GET /api/transactions",
+				"GET /api/transactions/{id}",
+				"POST /api/transactions/{id}/void",
+				"POST /api/transactions/{id}/refund",
+				"GET /api/transactions/{id}/receipt",
+				"GET /api/shifts",
+				"POST /api/shifts/open",
+				"GET /api/shifts/current",
+				"GET /api/shifts/{id}/report",
+				"POST /api/shifts/{id}/close",
+				"GET /api/stock-opname",
+				"POST /api/stock-opname",
+				"GET /api/stock-opname/{id}",
+				"POST /api/stock-opname/{id}/counts",
+				"POST /api/stock-opname/{id}/commit",
+				"POST /api/stock-opname/{id}/cancel",
+				"GET /api/suppliers",
+				"POST /api/suppliers",
+				"GET /api/suppliers/{id}",
+				"PUT /api/suppliers/{id}",
+				"DELETE /api/suppliers/{id}",
+				"GET /api/purchase-orders",
+				"POST /api/purchase-orders",
+				"GET /api/purchase-orders/{id}",
+				"PUT /api/purchase-orders/{id}",
+				"POST /api/purchase-orders/{id}/order",
+				"POST /api/purchase-orders/{id}/cancel",
+				"POST /api/purchase-orders/{id}/receive",
 			},
 		})
 	})
@@ -256,7 +443,7 @@ func main() {
 
 	fmt.Println("Server running di", addr)
 
-	err := http.ListenAndServe(addr, loggedMux)
+	err = http.ListenAndServe(addr, loggedMux)
 	if err != nil {
 		fmt.Println("gagal running server", err)
 	}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"kasir-api/auth"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type contextKey string
+
+const claimsKey contextKey = "claims"
+
+// Roles lists who may call a route. Read applies to GET and HEAD requests, Write
+// to every other method. An empty list denies that kind of request to everyone.
+type Roles struct {
+	Read  []string
+	Write []string
+}
+
+// Authenticator checks the Bearer token of a request and the role inside it.
+type Authenticator struct {
+	secret []byte
+}
+
+func NewAuthenticator(secret []byte) *Authenticator {
+	return &Authenticator{secret: secret}
+}
+
+// Require wraps next so it only runs for a valid token whose role is allowed by
+// roles. It answers 401 when the token is missing or invalid and 403 when the
+// role is not allowed.
+func (a *Authenticator) Require(roles Roles, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := auth.ParseToken(token, a.secret)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			if errors.Is(err, auth.ErrExpiredToken) {
+				http.Error(w, "Token expired", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			}
+			return
+		}
+
+		allowed := roles.Write
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			allowed = roles.Read
+		}
+		if !slices.Contains(allowed, claims.Role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), claimsKey, claims)))
+	}
+}
+
+// UserFromContext returns the claims of the authenticated user, set by Require.
+func UserFromContext(ctx context.Context) (*auth.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*auth.Claims)
+	return claims, ok
+}
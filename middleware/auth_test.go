@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"kasir-api/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequire(t *testing.T) {
+	secret := []byte("test-secret")
+	a := NewAuthenticator(secret)
+	handler := a.Require(Roles{Read: []string{"admin", "cashier"}, Write: []string{"admin"}}, func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := UserFromContext(r.Context())
+		if !ok {
+			t.Fatal("claims missing from context")
+		}
+		w.Write([]byte(claims.Username))
+	})
+
+	token := func(role string, exp time.Time) string {
+		tok, err := auth.SignToken(auth.Claims{UserID: 1, Username: "budi", Role: role, ExpiresAt: exp.Unix()}, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + tok
+	}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		want   int
+	}{
+		{"no token", http.MethodGet, "", http.StatusUnauthorized},
+		{"bad token", http.MethodGet, "Bearer nope", http.StatusUnauthorized},
+		{"expired", http.MethodGet, token("admin", time.Now().Add(-time.Minute)), http.StatusUnauthorized},
+		{"cashier read", http.MethodGet, token("cashier", later), http.StatusOK},
+		{"cashier write", http.MethodDelete, token("cashier", later), http.StatusForbidden},
+		{"admin write", http.MethodPut, token("admin", later), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/produk/1", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
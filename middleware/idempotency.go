@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"kasir-api/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+// IdempotencyStore keeps the first response given for each Idempotency-Key.
+type IdempotencyStore interface {
+	// Claim returns nil when the key is free and now taken by rec, or the
+	// record of the earlier request that used it.
+	Claim(rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	Complete(rec *models.IdempotencyRecord) error
+}
+
+const maxIdempotencyKeyLength = 255
+
+// Idempotency makes retried requests safe. A request carrying an
+// Idempotency-Key header runs once per user and key; repeating it with the same
+// body replays the first response, whatever its status, instead of running the
+// handler again. Requests without the header are passed through untouched.
+type Idempotency struct {
+	store IdempotencyStore
+}
+
+func NewIdempotency(store IdempotencyStore) *Idempotency {
+	return &Idempotency{store: store}
+}
+
+// Wrap applies idempotency keys to next. It must run inside Require, since keys
+// are scoped to the authenticated user.
+func (i *Idempotency) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+		claims, ok := UserFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := &models.IdempotencyRecord{
+			UserID:      claims.UserID,
+			Key:         key,
+			RequestHash: requestHash(r, body),
+			CreatedAt:   time.Now(),
+		}
+		existing, err := i.store.Claim(rec)
+		if err != nil {
+			log.Printf("idempotency key %q: %v", key, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.RequestHash != rec.RequestHash:
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+			case existing.StatusCode == 0:
+				http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+			default:
+				if existing.ContentType != "" {
+					w.Header().Set("Content-Type", existing.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(existing.StatusCode)
+				w.Write(existing.Body)
+			}
+			return
+		}
+
+		rw := &recordingWriter{ResponseWriter: w}
+		next(rw, r)
+
+		rec.StatusCode = rw.status()
+		rec.ContentType = w.Header().Get("Content-Type")
+		rec.Body = rw.body.Bytes()
+		if err := i.store.Complete(rec); err != nil {
+			log.Printf("idempotency key %q: failed to save response: %v", key, err)
+		}
+	}
+}
+
+// requestHash fingerprints the method, URL and body of a request.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter passes the response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.code == 0 {
+		rw.code = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.code == 0 {
+		rw.code = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recordingWriter) status() int {
+	if rw.code == 0 {
+		return http.StatusOK
+	}
+	return rw.code
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"kasir-api/auth"
+	"kasir-api/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type memoryIdempotencyStore struct {
+	records map[string]*models.IdempotencyRecord
+}
+
+func (s *memoryIdempotencyStore) Claim(rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	if existing, ok := s.records[rec.Key]; ok {
+		copied := *existing
+		return &copied, nil
+	}
+	claimed := *rec
+	s.records[rec.Key] = &claimed
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(rec *models.IdempotencyRecord) error {
+	completed := *rec
+	s.records[rec.Key] = &completed
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	store := &memoryIdempotencyStore{records: make(map[string]*models.IdempotencyRecord)}
+	calls := 0
+	handler := NewIdempotency(store).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/checkout", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), claimsKey, &auth.Claims{UserID: 1, Role: "cashier"}))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	first := send("abc", `{"items":[]}`)
+	if first.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("first request: status %d after %d calls", first.Code, calls)
+	}
+
+	replay := send("abc", `{"items":[]}`)
+	if replay.Code != http.StatusCreated || replay.Body.String() != `{"id":1}` || calls != 1 {
+		t.Errorf("retry: expected replay of 201 without calling the handler, got %d %q after %d calls", replay.Code, replay.Body.String(), calls)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" || replay.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("retry: unexpected headers %v", replay.Header())
+	}
+
+	if rec := send("abc", `{"items":[{"product_id":1}]}`); rec.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Errorf("reuse with another body: expected 422, got %d after %d calls", rec.Code, calls)
+	}
+
+	store.records["busy"] = &models.IdempotencyRecord{Key: "busy", RequestHash: store.records["abc"].RequestHash}
+	if rec := send("busy", `{"items":[]}`); rec.Code != http.StatusConflict {
+		t.Errorf("key still in progress: expected 409, got %d", rec.Code)
+	}
+
+	send("", `{"items":[]}`)
+	send("", `{"items":[]}`)
+	if calls != 3 {
+		t.Errorf("requests without a key should always run, got %d calls", calls)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"kasir-api/database"
+	"kasir-api/migrations"
+	"log"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `Usage: kasir-api migrate <command> [steps]
+
+Commands:
+  up [N]     apply all pending migrations, or only the next N
+  down [N]   revert the last N applied migrations (default 1)
+  status     list migrations and whether they are applied`
+
+func migrateUp(steps int) error {
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(steps)
+	for _, m := range applied {
+		fmt.Printf("Applied migration %03d_%s\n", m.Version, m.Name)
+	}
+	return err
+}
+
+// runMigrate handles `kasir-api migrate up|down|status [N]`.
+func runMigrate(config Config, args []string) {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+
+	steps := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid number of steps %q", args[1])
+		}
+		steps = n
+	}
+
+	if config.DBConn == "" {
+		log.Fatal("DB_CONN is required to run migrations")
+	}
+	var err error
+	db, err = database.InitDB(config.DBConn)
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		log.Fatal("Failed to load migrations:", err)
+	}
+
+	switch args[0] {
+	case "up":
+		if err := migrateUp(steps); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Database is up to date")
+	case "down":
+		if steps == 0 {
+			steps = 1
+		}
+		reverted, err := migrator.Down(steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted migration %03d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+}
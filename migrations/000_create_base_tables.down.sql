@@ -0,0 +1,5 @@
+-- Migration: 000_create_base_tables.down.sql
+DROP TABLE IF EXISTS transaction_details;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
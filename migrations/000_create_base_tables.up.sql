@@ -0,0 +1,28 @@
+-- Migration: 000_create_base_tables.up.sql
+-- The original categories, products and transactions tables
+CREATE TABLE IF NOT EXISTS categories (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	price INTEGER NOT NULL,
+	stock INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+	id SERIAL PRIMARY KEY,
+	total_amount INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS transaction_details (
+	id SERIAL PRIMARY KEY,
+	transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+	product_id INTEGER NOT NULL REFERENCES products(id),
+	quantity INTEGER NOT NULL,
+	subtotal INTEGER NOT NULL
+);
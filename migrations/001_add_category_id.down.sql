@@ -0,0 +1,2 @@
+-- Migration: 001_add_category_id.down.sql
+ALTER TABLE products DROP COLUMN IF EXISTS category_id;
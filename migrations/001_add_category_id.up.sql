@@ -1,5 +1,3 @@
--- Migration: 001_add_category_id.sql
+-- Migration: 001_add_category_id.up.sql
 -- Adds category_id column to products table if it doesn't exist
-BEGIN;
 ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories(id);
-COMMIT;
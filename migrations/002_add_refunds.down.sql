@@ -0,0 +1,5 @@
+-- Migration: 002_add_refunds.down.sql
+DROP TABLE IF EXISTS refund_items;
+DROP TABLE IF EXISTS refunds;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS refunded_quantity;
+ALTER TABLE transactions DROP COLUMN IF EXISTS status;
@@ -0,0 +1,21 @@
+-- Migration: 002_add_refunds.up.sql
+-- Adds transaction status, refunded quantities and the refunds tables used by void/refund
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'completed';
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS refunded_quantity INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS refunds (
+	id SERIAL PRIMARY KEY,
+	transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+	reason TEXT NOT NULL,
+	amount INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+	id SERIAL PRIMARY KEY,
+	refund_id INTEGER NOT NULL REFERENCES refunds(id),
+	transaction_detail_id INTEGER NOT NULL REFERENCES transaction_details(id),
+	product_id INTEGER NOT NULL REFERENCES products(id),
+	quantity INTEGER NOT NULL,
+	amount INTEGER NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Migration: 003_add_payments.down.sql
+DROP TABLE IF EXISTS payments;
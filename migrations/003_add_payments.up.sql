@@ -0,0 +1,12 @@
+-- Migration: 003_add_payments.up.sql
+-- Records how each transaction was paid
+CREATE TABLE IF NOT EXISTS payments (
+	id SERIAL PRIMARY KEY,
+	transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+	method VARCHAR(20) NOT NULL,
+	amount INTEGER NOT NULL,
+	amount_tendered INTEGER NOT NULL,
+	change_amount INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_payments_transaction_id ON payments(transaction_id);
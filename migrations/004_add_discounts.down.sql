@@ -0,0 +1,7 @@
+-- Migration: 004_add_discounts.down.sql
+DROP TABLE IF EXISTS transaction_discounts;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS discount_amount;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS gross_subtotal;
+ALTER TABLE transactions DROP COLUMN IF EXISTS discount_amount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS gross_amount;
+DROP TABLE IF EXISTS discounts;
@@ -0,0 +1,36 @@
+-- Migration: 004_add_discounts.up.sql
+-- Discount rules and the discounts applied on each transaction
+CREATE TABLE IF NOT EXISTS discounts (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	type VARCHAR(20) NOT NULL,
+	value INTEGER NOT NULL,
+	scope VARCHAR(20) NOT NULL,
+	product_id INTEGER REFERENCES products(id),
+	category_id INTEGER REFERENCES categories(id),
+	promo_code VARCHAR(50) UNIQUE,
+	min_purchase INTEGER NOT NULL DEFAULT 0,
+	starts_at TIMESTAMP,
+	ends_at TIMESTAMP,
+	active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS gross_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS discount_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS gross_subtotal INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS discount_amount INTEGER NOT NULL DEFAULT 0;
+
+-- Transactions created before discounts existed were sold at full price
+UPDATE transactions SET gross_amount = total_amount WHERE gross_amount = 0;
+UPDATE transaction_details SET gross_subtotal = subtotal WHERE gross_subtotal = 0;
+
+CREATE TABLE IF NOT EXISTS transaction_discounts (
+	id SERIAL PRIMARY KEY,
+	transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+	transaction_detail_id INTEGER REFERENCES transaction_details(id),
+	discount_id INTEGER NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	scope VARCHAR(20) NOT NULL,
+	amount INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_transaction_discounts_transaction_id ON transaction_discounts(transaction_id);
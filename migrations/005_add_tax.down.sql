@@ -0,0 +1,12 @@
+-- Migration: 005_add_tax.down.sql
+ALTER TABLE refunds DROP COLUMN IF EXISTS service_charge;
+ALTER TABLE refunds DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS line_total;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS tax_rate;
+ALTER TABLE transactions DROP COLUMN IF EXISTS tax_inclusive;
+ALTER TABLE transactions DROP COLUMN IF EXISTS service_charge;
+ALTER TABLE transactions DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS subtotal;
+ALTER TABLE products DROP COLUMN IF EXISTS tax_exempt;
+ALTER TABLE categories DROP COLUMN IF EXISTS tax_rate;
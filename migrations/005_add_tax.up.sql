@@ -0,0 +1,18 @@
+-- Migration: 005_add_tax.up.sql
+-- Tax (PPN) and service charge columns, per-category tax rates and tax-exempt products
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS tax_rate NUMERIC(5,2);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_exempt BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS subtotal INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS tax_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS service_charge INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS tax_rate NUMERIC(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS tax_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS line_total INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS tax_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS service_charge INTEGER NOT NULL DEFAULT 0;
+
+-- Transactions created before tax support carried no tax
+UPDATE transactions SET subtotal = total_amount WHERE subtotal = 0;
+UPDATE transaction_details SET line_total = subtotal WHERE line_total = 0;
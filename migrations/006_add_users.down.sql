@@ -0,0 +1,2 @@
+-- Migration: 006_add_users.down.sql
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,9 @@
+-- Migration: 006_add_users.up.sql
+-- Users for login, with admin and cashier roles
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(50) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,5 @@
+-- Migration: 007_add_cashier_register.down.sql
+DROP INDEX IF EXISTS idx_transactions_register_id;
+DROP INDEX IF EXISTS idx_transactions_cashier_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS register_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS cashier_id;
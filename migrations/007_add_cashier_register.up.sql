@@ -0,0 +1,7 @@
+-- Migration: 007_add_cashier_register.up.sql
+-- Cashier (logged-in user) and register/terminal of every transaction
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS cashier_id INTEGER REFERENCES users(id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS register_id VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_cashier_id ON transactions(cashier_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_register_id ON transactions(register_id);
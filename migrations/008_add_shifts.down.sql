@@ -0,0 +1,5 @@
+-- Migration: 008_add_shifts.down.sql
+ALTER TABLE refunds DROP COLUMN IF EXISTS shift_id;
+ALTER TABLE refunds DROP COLUMN IF EXISTS user_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS shift_id;
+DROP TABLE IF EXISTS shifts;
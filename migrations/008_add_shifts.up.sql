@@ -0,0 +1,26 @@
+-- Migration: 008_add_shifts.up.sql
+-- Cash drawer shifts; every transaction belongs to the cashier's open shift
+CREATE TABLE IF NOT EXISTS shifts (
+    id SERIAL PRIMARY KEY,
+    cashier_id INTEGER NOT NULL REFERENCES users(id),
+    register_id VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    opening_float INTEGER NOT NULL DEFAULT 0,
+    expected_cash INTEGER,
+    counted_cash INTEGER,
+    difference INTEGER,
+    notes TEXT,
+    opened_at TIMESTAMP NOT NULL,
+    closed_at TIMESTAMP
+);
+
+-- One open shift per cashier and per register
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_open_cashier ON shifts(cashier_id) WHERE status = 'open';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_open_register ON shifts(register_id) WHERE status = 'open';
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS shift_id INTEGER REFERENCES shifts(id);
+CREATE INDEX IF NOT EXISTS idx_transactions_shift_id ON transactions(shift_id);
+
+-- Refunds paid out of a drawer count against that shift
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id);
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS shift_id INTEGER REFERENCES shifts(id);
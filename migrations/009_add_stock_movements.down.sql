@@ -0,0 +1,2 @@
+-- Migration: 009_add_stock_movements.down.sql
+DROP TABLE IF EXISTS stock_movements;
@@ -0,0 +1,20 @@
+-- Migration: 009_add_stock_movements.up.sql
+-- Ledger of every stock change: sales, refunds, restocks, adjustments and stock opname
+CREATE TABLE IF NOT EXISTS stock_movements (
+	id SERIAL PRIMARY KEY,
+	product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	type VARCHAR(20) NOT NULL,
+	quantity INTEGER NOT NULL,
+	stock_after INTEGER NOT NULL,
+	reason TEXT,
+	reference_id INTEGER,
+	user_id INTEGER REFERENCES users(id),
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id ON stock_movements(product_id, created_at);
+
+-- Opening balance so every product's ledger adds up to its current stock
+INSERT INTO stock_movements (product_id, type, quantity, stock_after, reason, created_at)
+SELECT id, 'adjustment', stock, stock, 'saldo awal', NOW()
+FROM products
+WHERE stock <> 0;
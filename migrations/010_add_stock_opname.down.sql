@@ -0,0 +1,3 @@
+-- Migration: 010_add_stock_opname.down.sql
+DROP TABLE IF EXISTS stock_opname_items;
+DROP TABLE IF EXISTS stock_opnames;
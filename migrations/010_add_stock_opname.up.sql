@@ -0,0 +1,28 @@
+-- Migration: 010_add_stock_opname.up.sql
+-- Stock opname (physical count) sessions and the quantities counted in them
+CREATE TABLE IF NOT EXISTS stock_opnames (
+    id SERIAL PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    notes TEXT,
+    created_by INTEGER REFERENCES users(id),
+    committed_by INTEGER REFERENCES users(id),
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP
+);
+
+-- Only one count can be in progress at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_opnames_open ON stock_opnames(status) WHERE status = 'open';
+
+-- system_stock is recorded when the product is counted; variance and unit_cost
+-- (the cost price variances are valued at) are filled in when the session is committed
+CREATE TABLE IF NOT EXISTS stock_opname_items (
+    id SERIAL PRIMARY KEY,
+    opname_id INTEGER NOT NULL REFERENCES stock_opnames(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    counted_quantity INTEGER NOT NULL,
+    system_stock INTEGER,
+    variance INTEGER,
+    unit_cost INTEGER,
+    counted_at TIMESTAMP NOT NULL,
+    UNIQUE (opname_id, product_id)
+);
@@ -0,0 +1,3 @@
+-- Migration: 011_add_reorder_levels.down.sql
+ALTER TABLE products DROP COLUMN IF EXISTS reorder_qty;
+ALTER TABLE products DROP COLUMN IF EXISTS reorder_point;
@@ -0,0 +1,4 @@
+-- Migration: 011_add_reorder_levels.up.sql
+-- Reorder point (NULL = no low-stock alert) and the quantity to order when it is reached
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_point INTEGER;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_qty INTEGER NOT NULL DEFAULT 0;
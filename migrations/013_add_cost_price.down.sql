@@ -0,0 +1,3 @@
+-- Migration: 013_add_cost_price.down.sql
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS unit_cost;
+ALTER TABLE products DROP COLUMN IF EXISTS cost_price;
@@ -0,0 +1,4 @@
+-- Migration: 013_add_cost_price.up.sql
+-- Weighted-average cost of the goods in stock, and the cost of each line at sale time
+ALTER TABLE products ADD COLUMN IF NOT EXISTS cost_price INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS unit_cost INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Migration: 014_snapshot_transaction_details.down.sql
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS category_name;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS category_id;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS unit_price;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS product_name;
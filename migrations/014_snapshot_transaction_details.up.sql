@@ -0,0 +1,15 @@
+-- Migration: 014_snapshot_transaction_details.up.sql
+-- Product name, unit price and category as they were at sale time, so renaming
+-- or moving a product does not rewrite sales history
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS product_name VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS unit_price INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS category_id INTEGER;
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS category_name VARCHAR(100) NOT NULL DEFAULT '';
+
+-- Older rows only have the live product to go by
+UPDATE transaction_details td
+SET product_name = p.name, category_id = p.category_id, category_name = COALESCE(c.name, '')
+FROM products p
+LEFT JOIN categories c ON p.category_id = c.id
+WHERE td.product_id = p.id AND td.product_name = '';
+UPDATE transaction_details SET unit_price = gross_subtotal / quantity WHERE unit_price = 0 AND quantity > 0;
@@ -0,0 +1,3 @@
+-- Migration: 015_add_archiving.down.sql
+ALTER TABLE categories DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE products DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,4 @@
+-- Migration: 015_add_archiving.up.sql
+-- Products and categories are archived instead of deleted so sales history keeps its references
+ALTER TABLE products ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
@@ -0,0 +1,3 @@
+-- Migration: 016_add_barcodes.down.sql
+DROP TABLE IF EXISTS product_barcodes;
+ALTER TABLE products DROP COLUMN IF EXISTS sku;
@@ -0,0 +1,10 @@
+-- Migration: 016_add_barcodes.up.sql
+-- Optional unique SKU per product and any number of scannable barcodes
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64) UNIQUE;
+
+CREATE TABLE IF NOT EXISTS product_barcodes (
+	id SERIAL PRIMARY KEY,
+	product_id INTEGER NOT NULL REFERENCES products(id),
+	code VARCHAR(13) NOT NULL UNIQUE
+);
+CREATE INDEX IF NOT EXISTS idx_product_barcodes_product_id ON product_barcodes(product_id);
@@ -0,0 +1,2 @@
+-- Migration: 017_add_idempotency_keys.down.sql
+DROP TABLE IF EXISTS idempotency_keys;
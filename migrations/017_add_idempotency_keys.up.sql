@@ -0,0 +1,16 @@
+-- Migration: 017_add_idempotency_keys.up.sql
+-- First response per client-supplied Idempotency-Key, replayed for retries
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	user_id INTEGER NOT NULL,
+	key VARCHAR(255) NOT NULL,
+	request_hash CHAR(64) NOT NULL,
+	-- identifies the request holding the key, so a request whose key was taken
+	-- over cannot overwrite the response of the request that took it
+	claim_token CHAR(32) NOT NULL DEFAULT '',
+	status_code INTEGER,
+	content_type VARCHAR(100) NOT NULL DEFAULT '',
+	response_body BYTEA,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, key)
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
@@ -0,0 +1,3 @@
+-- Migration: 018_add_carts.down.sql
+DROP TABLE IF EXISTS cart_items;
+DROP TABLE IF EXISTS carts;
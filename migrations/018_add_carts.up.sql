@@ -0,0 +1,24 @@
+-- Migration: 018_add_carts.up.sql
+-- Held carts and open bills, turned into a transaction at checkout
+CREATE TABLE IF NOT EXISTS carts (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL DEFAULT '',
+	status VARCHAR(20) NOT NULL DEFAULT 'open',
+	promo_code VARCHAR(50) NOT NULL DEFAULT '',
+	created_by INTEGER REFERENCES users(id),
+	transaction_id INTEGER REFERENCES transactions(id),
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_carts_status ON carts(status);
+
+-- Cart lines can hold packs; unit '' is the product's base unit. A product can
+-- be in a cart once per unit
+CREATE TABLE IF NOT EXISTS cart_items (
+	id SERIAL PRIMARY KEY,
+	cart_id INTEGER NOT NULL REFERENCES carts(id),
+	product_id INTEGER NOT NULL REFERENCES products(id),
+	unit VARCHAR(20) NOT NULL DEFAULT '',
+	quantity INTEGER NOT NULL,
+	UNIQUE (cart_id, product_id, unit)
+);
@@ -0,0 +1,13 @@
+-- Migration: 019_add_stock_reservations.up.sql
+-- Stock held for parked carts. Reserved quantities are not on-hand stock that
+-- can be sold; expires_at is compared with NOW(), hence TIMESTAMPTZ.
+CREATE TABLE IF NOT EXISTS stock_reservations (
+	id SERIAL PRIMARY KEY,
+	cart_id INTEGER NOT NULL REFERENCES carts(id),
+	product_id INTEGER NOT NULL REFERENCES products(id),
+	quantity INTEGER NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	UNIQUE (cart_id, product_id)
+);
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_product ON stock_reservations(product_id, expires_at);
@@ -0,0 +1,5 @@
+-- Migration: 020_add_product_units.down.sql
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS unit_factor;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS unit;
+DROP TABLE IF EXISTS product_units;
+ALTER TABLE products DROP COLUMN IF EXISTS base_unit;
@@ -0,0 +1,17 @@
+-- Migration: 020_add_product_units.up.sql
+-- Stock is kept in the product's base unit; product_units are the packs it is
+-- also sold in, each holding factor base units at its own price.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS base_unit VARCHAR(20) NOT NULL DEFAULT 'pcs';
+
+CREATE TABLE IF NOT EXISTS product_units (
+	id SERIAL PRIMARY KEY,
+	product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	unit VARCHAR(20) NOT NULL,
+	factor INTEGER NOT NULL CHECK (factor > 1),
+	price INTEGER NOT NULL,
+	UNIQUE (product_id, unit)
+);
+
+-- Sold quantities stay in the unit they were sold in
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS unit VARCHAR(20) NOT NULL DEFAULT 'pcs';
+ALTER TABLE transaction_details ADD COLUMN IF NOT EXISTS unit_factor INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+// Package migrations holds the numbered SQL migrations of the database schema.
+// Every version has a NNN_name.up.sql file and a matching NNN_name.down.sql file;
+// the runner in package database wraps each file in a transaction.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
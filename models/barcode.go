@@ -0,0 +1,31 @@
+package models
+
+// IsValidBarcode reports whether code is an EAN-8, UPC-A (12 digits) or EAN-13
+// barcode with a correct check digit.
+func IsValidBarcode(code string) bool {
+	switch len(code) {
+	case 8, 12, 13:
+	default:
+		return false
+	}
+
+	// Weights alternate 3, 1, ... starting from the digit left of the check digit
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		c := code[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	check := code[len(code)-1]
+	if check < '0' || check > '9' {
+		return false
+	}
+	return int(check-'0') == (10-sum%10)%10
+}
@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestIsValidBarcode(t *testing.T) {
+	cases := map[string]bool{
+		"8992388101016": true,  // EAN-13
+		"4006381333931": true,  // EAN-13
+		"036000291452":  true,  // UPC-A
+		"96385074":      true,  // EAN-8
+		"8992388101017": false, // wrong check digit
+		"036000291453":  false,
+		"89923881010a6": false,
+		"12345":         false,
+		"":              false,
+	}
+
+	for code, want := range cases {
+		if got := IsValidBarcode(code); got != want {
+			t.Errorf("IsValidBarcode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+const (
+	CartStatusOpen       = "open"
+	CartStatusParked     = "parked"
+	CartStatusCheckedOut = "checked_out"
+	CartStatusCancelled  = "cancelled"
+)
+
+// Cart is a basket that can be parked and resumed before it is paid, such as an
+// open bill in the cafe. Items and totals are priced at current prices with the
+// discounts and tax checkout would apply right now.
+type Cart struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	PromoCode     string `json:"promo_code,omitempty"`
+	CreatedBy     *int   `json:"created_by"`
+	CreatedByName string `json:"created_by_name,omitempty"`
+	TransactionID *int   `json:"transaction_id"`
+	// ReservedUntil is when the stock held for a parked cart is released; nil
+	// when the cart holds no stock.
+	ReservedUntil  *time.Time `json:"reserved_until"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Items          []CartItem `json:"items,omitempty"`
+	GrossAmount    int        `json:"gross_amount"`
+	DiscountAmount int        `json:"discount_amount"`
+	Subtotal       int        `json:"subtotal"`
+	TaxAmount      int        `json:"tax_amount"`
+	ServiceCharge  int        `json:"service_charge"`
+	TotalAmount    int        `json:"total_amount"`
+	// PromoError says why PromoCode does not apply at the moment; totals are
+	// then shown without it.
+	PromoError string `json:"promo_error,omitempty"`
+}
+
+// CartItem is one line of a cart. Unit is the product's base unit or one of its
+// packs, UnitPrice the price of that unit; Stock is in base units.
+type CartItem struct {
+	ID             int    `json:"id"`
+	ProductID      int    `json:"product_id"`
+	ProductName    string `json:"product_name"`
+	Unit           string `json:"unit"`
+	UnitFactor     int    `json:"unit_factor"`
+	UnitPrice      int    `json:"unit_price"`
+	Quantity       int    `json:"quantity"`
+	GrossSubtotal  int    `json:"gross_subtotal"`
+	DiscountAmount int    `json:"discount_amount"`
+	TaxAmount      int    `json:"tax_amount"`
+	LineTotal      int    `json:"line_total"`
+	Stock          int    `json:"stock"`
+}
+
+type CartRequest struct {
+	Name      string `json:"name"`
+	PromoCode string `json:"promo_code"`
+}
+
+// CartUpdateRequest changes a cart's name and promo code; a field left out keeps
+// its stored value and "" clears it.
+type CartUpdateRequest struct {
+	Name      *string `json:"name"`
+	PromoCode *string `json:"promo_code"`
+}
+
+// CartItemRequest adds a product, by product_id or barcode, to a cart. Unit is
+// one of its packs and defaults to the base unit. Adding a product that is
+// already in the cart in the same unit increases its quantity.
+type CartItemRequest struct {
+	ProductID int    `json:"product_id"`
+	Barcode   string `json:"barcode,omitempty"`
+	Unit      string `json:"unit,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// CartCheckoutRequest pays for a cart; see CheckoutRequest.
+type CartCheckoutRequest struct {
+	Payments    []PaymentRequest `json:"payments"`
+	Payment     *PaymentRequest  `json:"payment,omitempty"`
+	RegisterID  string           `json:"register_id"`
+	CashierID   int              `json:"-"`
+	CashierName string           `json:"-"`
+}
@@ -1,7 +1,13 @@
 package models
 
+import "time"
+
 type Category struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
-}
\ No newline at end of file
+	// TaxRate overrides the default tax rate (in percent) for products in this category.
+	TaxRate *float64 `json:"tax_rate,omitempty"`
+	// ArchivedAt is set when the category is deleted.
+	ArchivedAt *time.Time `json:"archived_at"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+const (
+	DiscountTypePercentage = "percentage"
+	DiscountTypeFixed      = "fixed"
+
+	DiscountScopeProduct  = "product"
+	DiscountScopeCategory = "category"
+	DiscountScopeCart     = "cart"
+)
+
+// Discount is a promotion rule. Value is a percentage (1-100) for percentage
+// discounts and an amount in rupiah for fixed ones; fixed product and category
+// discounts are given per unit, fixed cart discounts once per transaction.
+type Discount struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Value       int        `json:"value"`
+	Scope       string     `json:"scope"`
+	ProductID   *int       `json:"product_id,omitempty"`
+	CategoryID  *int       `json:"category_id,omitempty"`
+	PromoCode   *string    `json:"promo_code,omitempty"`
+	MinPurchase int        `json:"min_purchase"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	Active      bool       `json:"active"`
+}
+
+// Amount returns the discount for a line (or cart) worth base for quantity units,
+// never more than base itself.
+func (d Discount) Amount(base, quantity int) int {
+	amount := 0
+	switch d.Type {
+	case DiscountTypePercentage:
+		amount = base * d.Value / 100
+	case DiscountTypeFixed:
+		amount = d.Value
+		if d.Scope != DiscountScopeCart {
+			amount = d.Value * quantity
+		}
+	}
+	return min(amount, base)
+}
+
+// AppliedDiscount records a discount given on a transaction. TransactionDetailID is
+// nil for cart-level discounts.
+type AppliedDiscount struct {
+	ID                  int    `json:"id"`
+	TransactionID       int    `json:"transaction_id"`
+	TransactionDetailID *int   `json:"transaction_detail_id,omitempty"`
+	DiscountID          int    `json:"discount_id"`
+	Name                string `json:"name"`
+	Scope               string `json:"scope"`
+	Amount              int    `json:"amount"`
+}
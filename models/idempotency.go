@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the stored outcome of the first request made with an
+// Idempotency-Key. StatusCode is 0 while that request is still running.
+// ClaimToken identifies the request holding the key.
+type IdempotencyRecord struct {
+	UserID      int
+	Key         string
+	RequestHash string
+	ClaimToken  string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+const (
+	PaymentMethodCash    = "cash"
+	PaymentMethodQRIS    = "qris"
+	PaymentMethodDebit   = "debit"
+	PaymentMethodEWallet = "ewallet"
+)
+
+// IsValidPaymentMethod reports whether method is one of the accepted tenders.
+func IsValidPaymentMethod(method string) bool {
+	switch method {
+	case PaymentMethodCash, PaymentMethodQRIS, PaymentMethodDebit, PaymentMethodEWallet:
+		return true
+	}
+	return false
+}
+
+type PaymentRequest struct {
+	Method         string `json:"method"`
+	AmountTendered int    `json:"amount_tendered"`
+}
+
+type Payment struct {
+	ID             int       `json:"id"`
+	TransactionID  int       `json:"transaction_id"`
+	Method         string    `json:"method"`
+	Amount         int       `json:"amount"`
+	AmountTendered int       `json:"amount_tendered"`
+	Change         int       `json:"change"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type PaymentMethodSummary struct {
+	Method string `json:"method"`
+	Count  int    `json:"count"`
+	Total  int    `json:"total"`
+}
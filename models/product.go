@@ -1,10 +1,57 @@
 package models
 
+import "time"
+
 type Product struct {
-	ID           int    `json:"id"`
-	Name         string `json:"name"`
-	Price        int    `json:"price"`
-	Stock        int    `json:"stock"`
-	CategoryID   int    `json:"category_id"`
-	CategoryName string `json:"category_name"`
+	ID int `json:"id"`
+	// SKU is an optional unique stock code; Barcodes are the EAN/UPC codes that
+	// scan to this product.
+	SKU      string   `json:"sku,omitempty"`
+	Barcodes []string `json:"barcodes"`
+	Name     string   `json:"name"`
+	// Price is per base unit.
+	Price int `json:"price"`
+	// CostPrice is the weighted-average cost per base unit, updated on goods receipt;
+	// nil on update keeps the stored cost.
+	CostPrice *int `json:"cost_price"`
+	// Stock is the quantity on hand in BaseUnit. Reserved of it is held for
+	// parked carts and cannot be sold to anyone else, leaving Available.
+	Stock     int    `json:"stock"`
+	Reserved  int    `json:"reserved_stock"`
+	Available int    `json:"available_stock"`
+	BaseUnit  string `json:"base_unit"`
+	// Units are the packs the product is also sold in; nil on update keeps them.
+	Units        []ProductUnit `json:"units"`
+	CategoryID   int           `json:"category_id"`
+	CategoryName string        `json:"category_name"`
+	TaxExempt    bool          `json:"tax_exempt"`
+	// ReorderPoint is the stock level at which the product needs reordering;
+	// nil turns low-stock alerts off for the product.
+	ReorderPoint *int `json:"reorder_point"`
+	ReorderQty   int  `json:"reorder_qty"`
+	// ArchivedAt is set when the product is deleted; archived products cannot be sold.
+	ArchivedAt *time.Time `json:"archived_at"`
+}
+
+// DefaultBaseUnit is the base unit of products that do not name one.
+const DefaultBaseUnit = "pcs"
+
+// ProductUnit is a pack of Factor base units, such as a dus of 40 pcs, sold at
+// its own Price.
+type ProductUnit struct {
+	Unit   string `json:"unit"`
+	Factor int    `json:"factor"`
+	Price  int    `json:"price"`
+}
+
+// LowStockEvent is emitted when a checkout takes a product's stock from above
+// its reorder point to at or below it.
+type LowStockEvent struct {
+	ProductID     int       `json:"product_id"`
+	ProductName   string    `json:"product_name"`
+	Stock         int       `json:"stock"`
+	ReorderPoint  int       `json:"reorder_point"`
+	ReorderQty    int       `json:"reorder_qty"`
+	TransactionID int       `json:"transaction_id"`
+	OccurredAt    time.Time `json:"occurred_at"`
 }
@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+// Purchase order statuses. A draft can be edited; once ordered, goods are
+// received against it until every line is complete.
+const (
+	PurchaseOrderStatusDraft             = "draft"
+	PurchaseOrderStatusOrdered           = "ordered"
+	PurchaseOrderStatusPartiallyReceived = "partially_received"
+	PurchaseOrderStatusReceived          = "received"
+	PurchaseOrderStatusCancelled         = "cancelled"
+)
+
+type PurchaseOrder struct {
+	ID           int                 `json:"id"`
+	SupplierID   int                 `json:"supplier_id"`
+	SupplierName string              `json:"supplier_name,omitempty"`
+	Status       string              `json:"status"`
+	Notes        string              `json:"notes,omitempty"`
+	CreatedBy    *int                `json:"created_by"`
+	TotalCost    int                 `json:"total_cost"`
+	CreatedAt    time.Time           `json:"created_at"`
+	OrderedAt    *time.Time          `json:"ordered_at"`
+	ClosedAt     *time.Time          `json:"closed_at"`
+	Items        []PurchaseOrderItem `json:"items,omitempty"`
+	Receipts     []GoodsReceipt      `json:"receipts,omitempty"`
+}
+
+// PurchaseOrderItem is one ordered product. UnitCost is the agreed cost per unit.
+type PurchaseOrderItem struct {
+	ID               int    `json:"id"`
+	PurchaseOrderID  int    `json:"purchase_order_id"`
+	ProductID        int    `json:"product_id"`
+	ProductName      string `json:"product_name,omitempty"`
+	Quantity         int    `json:"quantity"`
+	UnitCost         int    `json:"unit_cost"`
+	ReceivedQuantity int    `json:"received_quantity"`
+}
+
+type PurchaseOrderItemRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+	UnitCost  int `json:"unit_cost"`
+}
+
+// PurchaseOrderRequest creates a draft order or replaces a draft's lines.
+type PurchaseOrderRequest struct {
+	SupplierID int                        `json:"supplier_id"`
+	Notes      string                     `json:"notes"`
+	Items      []PurchaseOrderItemRequest `json:"items"`
+	CreatedBy  int                        `json:"-"`
+}
+
+type PurchaseOrderFilter struct {
+	Status     string
+	SupplierID int
+}
+
+// GoodsReceipt is one delivery received against a purchase order.
+type GoodsReceipt struct {
+	ID              int                `json:"id"`
+	PurchaseOrderID int                `json:"purchase_order_id"`
+	Notes           string             `json:"notes,omitempty"`
+	ReceivedBy      *int               `json:"received_by"`
+	ReceivedAt      time.Time          `json:"received_at"`
+	Items           []GoodsReceiptItem `json:"items"`
+}
+
+type GoodsReceiptItem struct {
+	ID                  int `json:"id"`
+	PurchaseOrderItemID int `json:"purchase_order_item_id"`
+	ProductID           int `json:"product_id"`
+	Quantity            int `json:"quantity"`
+	UnitCost            int `json:"unit_cost"`
+}
+
+// ReceiveItemRequest receives Quantity units of a product on the order. UnitCost
+// is the cost actually paid and defaults to the ordered cost when omitted.
+type ReceiveItemRequest struct {
+	ProductID int  `json:"product_id"`
+	Quantity  int  `json:"quantity"`
+	UnitCost  *int `json:"unit_cost"`
+}
+
+type ReceiveGoodsRequest struct {
+	Items      []ReceiveItemRequest `json:"items"`
+	Notes      string               `json:"notes"`
+	ReceivedBy int                  `json:"-"`
+}
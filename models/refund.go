@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+const (
+	TransactionStatusCompleted         = "completed"
+	TransactionStatusPartiallyRefunded = "partially_refunded"
+	TransactionStatusRefunded          = "refunded"
+	TransactionStatusVoided            = "voided"
+)
+
+type Refund struct {
+	ID            int          `json:"id"`
+	TransactionID int          `json:"transaction_id"`
+	Reason        string       `json:"reason"`
+	Amount        int          `json:"amount"`
+	TaxAmount     int          `json:"tax_amount"`
+	ServiceCharge int          `json:"service_charge"`
+	UserID        *int         `json:"user_id"`
+	ShiftID       *int         `json:"shift_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Items         []RefundItem `json:"items"`
+}
+
+type RefundItem struct {
+	ID                  int `json:"id"`
+	RefundID            int `json:"refund_id"`
+	TransactionDetailID int `json:"transaction_detail_id"`
+	ProductID           int `json:"product_id"`
+	Quantity            int `json:"quantity"`
+	Amount              int `json:"amount"`
+}
+
+type RefundItemRequest struct {
+	DetailID int `json:"detail_id"`
+	Quantity int `json:"quantity"`
+}
+
+type RefundRequest struct {
+	Reason string              `json:"reason"`
+	Items  []RefundItemRequest `json:"items"`
+	// UserID is who issues the refund, taken from the login token.
+	UserID int `json:"-"`
+}
@@ -1,12 +1,87 @@
 package models
 
+import "math"
+
 type BestSellingProd struct {
 	Nama       string `json:"nama"`
 	QtyTerjual int    `json:"qty_terjual"`
 }
 
 type SalesSummary struct {
-	TotalRevenue   int             `json:"total_revenue"`
-	TotalTransaksi int             `json:"total_transaksi"`
-	ProdukTerlaris BestSellingProd `json:"produk_terlaris"`
+	GrossSales     int                    `json:"gross_sales"`
+	TotalDiscount  int                    `json:"total_discount"`
+	TotalSubtotal  int                    `json:"total_subtotal"`
+	TotalTax       int                    `json:"total_tax"`
+	TotalService   int                    `json:"total_service_charge"`
+	TotalSales     int                    `json:"total_sales"`
+	TotalRefund    int                    `json:"total_refund"`
+	TotalRevenue   int                    `json:"total_revenue"`
+	NetSales       int                    `json:"net_sales"`
+	TotalCOGS      int                    `json:"total_cogs"`
+	GrossProfit    int                    `json:"gross_profit"`
+	GrossMargin    float64                `json:"gross_margin"`
+	TotalTransaksi int                    `json:"total_transaksi"`
+	ProdukTerlaris BestSellingProd        `json:"produk_terlaris"`
+	PaymentMethods []PaymentMethodSummary `json:"payment_methods"`
+	Groups         []SalesGroup           `json:"groups,omitempty"`
+}
+
+const (
+	ReportGroupByCashier  = "cashier"
+	ReportGroupByRegister = "register"
+
+	ReportGroupByProduct  = "product"
+	ReportGroupByCategory = "category"
+	ReportGroupByDay      = "day"
+)
+
+// SalesGroup is one row of a report grouped per cashier or per register.
+// Refunds count against the cashier and register of the original sale; tax and
+// service are net of them.
+type SalesGroup struct {
+	CashierID      *int   `json:"cashier_id,omitempty"`
+	CashierName    string `json:"cashier_name,omitempty"`
+	RegisterID     string `json:"register_id,omitempty"`
+	TotalTransaksi int    `json:"total_transaksi"`
+	TotalSales     int    `json:"total_sales"`
+	TotalRefund    int    `json:"total_refund"`
+	TotalRevenue   int    `json:"total_revenue"`
+	TotalTax       int    `json:"total_tax"`
+	TotalService   int    `json:"total_service_charge"`
+}
+
+// MarginRow is the profit of one product, category or day. NetSales is what the
+// goods sold for after discounts, without tax and service charge, and COGS their
+// cost at sale time; both leave out refunded quantities. GrossMargin is
+// GrossProfit as a percentage of NetSales.
+type MarginRow struct {
+	ProductID    *int    `json:"product_id,omitempty"`
+	ProductName  string  `json:"product_name,omitempty"`
+	CategoryID   *int    `json:"category_id,omitempty"`
+	CategoryName string  `json:"category_name,omitempty"`
+	Date         string  `json:"date,omitempty"`
+	Quantity     int     `json:"quantity"`
+	NetSales     int     `json:"net_sales"`
+	COGS         int     `json:"cogs"`
+	GrossProfit  int     `json:"gross_profit"`
+	GrossMargin  float64 `json:"gross_margin"`
+}
+
+type MarginReport struct {
+	StartDate   string      `json:"start_date"`
+	EndDate     string      `json:"end_date"`
+	GroupBy     string      `json:"group_by"`
+	NetSales    int         `json:"net_sales"`
+	COGS        int         `json:"cogs"`
+	GrossProfit int         `json:"gross_profit"`
+	GrossMargin float64     `json:"gross_margin"`
+	Rows        []MarginRow `json:"rows"`
+}
+
+// Margin returns profit as a percentage of sales, rounded to two decimals.
+func Margin(profit, sales int) float64 {
+	if sales == 0 {
+		return 0
+	}
+	return math.Round(float64(profit)*10000/float64(sales)) / 100
 }
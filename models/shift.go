@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+const (
+	ShiftStatusOpen   = "open"
+	ShiftStatusClosed = "closed"
+)
+
+// Shift is one cashier's session on a register, from opening float to cash count.
+type Shift struct {
+	ID           int        `json:"id"`
+	CashierID    int        `json:"cashier_id"`
+	CashierName  string     `json:"cashier_name,omitempty"`
+	RegisterID   string     `json:"register_id"`
+	Status       string     `json:"status"`
+	OpeningFloat int        `json:"opening_float"`
+	ExpectedCash *int       `json:"expected_cash"`
+	CountedCash  *int       `json:"counted_cash"`
+	Difference   *int       `json:"difference"`
+	Notes        string     `json:"notes,omitempty"`
+	OpenedAt     time.Time  `json:"opened_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+}
+
+type OpenShiftRequest struct {
+	RegisterID   string `json:"register_id"`
+	OpeningFloat int    `json:"opening_float"`
+}
+
+type CloseShiftRequest struct {
+	CountedCash *int   `json:"counted_cash"`
+	Notes       string `json:"notes"`
+}
+
+// ShiftReport is the X report of an open shift, or the Z report once it is closed.
+// ExpectedCash is the opening float plus cash taken minus the cash share of refunds.
+type ShiftReport struct {
+	Shift          Shift                  `json:"shift"`
+	TotalTransaksi int                    `json:"total_transaksi"`
+	TotalSales     int                    `json:"total_sales"`
+	PaymentMethods []PaymentMethodSummary `json:"payment_methods"`
+	CashIn         int                    `json:"cash_in"`
+	CashRefunds    int                    `json:"cash_refunds"`
+	ExpectedCash   int                    `json:"expected_cash"`
+	CountedCash    *int                   `json:"counted_cash"`
+	Difference     *int                   `json:"difference"`
+}
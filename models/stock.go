@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Stock movement types. Every change to products.stock is recorded with one of these.
+const (
+	StockMovementSale        = "sale"
+	StockMovementRefund      = "refund"
+	StockMovementRestock     = "restock"
+	StockMovementAdjustment  = "adjustment"
+	StockMovementStockOpname = "stock_opname"
+)
+
+// StockMovement is one entry of a product's stock ledger. Quantity is the signed
+// change and StockAfter the stock level right after it. ReferenceID points to the
+// transaction, refund or other document that caused the change, depending on Type.
+type StockMovement struct {
+	ID          int       `json:"id"`
+	ProductID   int       `json:"product_id"`
+	Type        string    `json:"type"`
+	Quantity    int       `json:"quantity"`
+	StockAfter  int       `json:"stock_after"`
+	Reason      string    `json:"reason,omitempty"`
+	ReferenceID *int      `json:"reference_id"`
+	UserID      *int      `json:"user_id"`
+	Username    string    `json:"username,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type StockMovementList struct {
+	Data  []StockMovement `json:"data"`
+	Page  int             `json:"page"`
+	Limit int             `json:"limit"`
+	Total int             `json:"total"`
+}
+
+// Reasons accepted by POST /api/produk/{id}/stock.
+const (
+	StockReasonRestock    = "restock"
+	StockReasonDamaged    = "damaged"
+	StockReasonExpired    = "expired"
+	StockReasonCorrection = "correction"
+)
+
+// StockAdjustmentRequest changes a product's stock by a relative Quantity.
+// Restocks must add stock, damaged and expired goods must remove it, and
+// corrections may go either way.
+type StockAdjustmentRequest struct {
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+	Note     string `json:"note,omitempty"`
+}
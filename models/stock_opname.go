@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+const (
+	StockOpnameStatusOpen      = "open"
+	StockOpnameStatusCommitted = "committed"
+	StockOpnameStatusCancelled = "cancelled"
+)
+
+// StockOpname is a physical count session. Counts are collected while it is open
+// and applied to products.stock when it is committed.
+type StockOpname struct {
+	ID            int        `json:"id"`
+	Status        string     `json:"status"`
+	Notes         string     `json:"notes,omitempty"`
+	CreatedBy     *int       `json:"created_by"`
+	CreatedByName string     `json:"created_by_name,omitempty"`
+	CommittedBy   *int       `json:"committed_by"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+}
+
+// StockOpnameItem is the counted quantity of one product. SystemStock is the
+// stock when the product was counted. Variance is CountedQuantity - SystemStock,
+// so shrinkage is negative.
+type StockOpnameItem struct {
+	ProductID       int       `json:"product_id"`
+	ProductName     string    `json:"product_name"`
+	CountedQuantity int       `json:"counted_quantity"`
+	SystemStock     int       `json:"system_stock"`
+	Variance        int       `json:"variance"`
+	UnitCost        int       `json:"unit_cost"`
+	VarianceValue   int       `json:"variance_value"`
+	CountedAt       time.Time `json:"counted_at"`
+}
+
+type StartStockOpnameRequest struct {
+	Notes string `json:"notes"`
+}
+
+type StockOpnameCount struct {
+	ProductID       int  `json:"product_id"`
+	CountedQuantity *int `json:"counted_quantity"`
+}
+
+// StockOpnameCountRequest submits counts in bulk. Counting a product again
+// replaces its earlier count.
+type StockOpnameCountRequest struct {
+	Items []StockOpnameCount `json:"items"`
+}
+
+// StockOpnameReport is the variance preview of an open session, or the final
+// variance report once it is committed. Values are variance times cost price.
+type StockOpnameReport struct {
+	Opname            StockOpname       `json:"opname"`
+	Items             []StockOpnameItem `json:"items"`
+	ProductsCounted   int               `json:"products_counted"`
+	ProductsVariance  int               `json:"products_with_variance"`
+	ShrinkageQuantity int               `json:"shrinkage_quantity"`
+	ShrinkageValue    int               `json:"shrinkage_value"`
+	SurplusQuantity   int               `json:"surplus_quantity"`
+	SurplusValue      int               `json:"surplus_value"`
+	NetVarianceValue  int               `json:"net_variance_value"`
+}
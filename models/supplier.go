@@ -0,0 +1,10 @@
+package models
+
+type Supplier struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	ContactName string `json:"contact_name,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Address     string `json:"address,omitempty"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// TaxConfig holds the store-wide tax settings used at checkout. Rates are percentages.
+type TaxConfig struct {
+	// Rate is the default PPN rate, overridden per category by Category.TaxRate.
+	Rate float64
+	// Inclusive means product prices already include tax.
+	Inclusive bool
+	// ServiceChargeRate is applied to the pre-tax subtotal; 0 disables it.
+	ServiceChargeRate float64
+}
@@ -7,26 +7,96 @@ func GetCurrentTime() time.Time {
 }
 
 type Transaction struct {
-	ID          int                 `json:"id"`
-	TotalAmount int                 `json:"total_amount"`
-	CreatedAt   time.Time           `json:"created_at"`
-	Details     []TransactionDetail `json:"details"`
+	ID             int                 `json:"id"`
+	GrossAmount    int                 `json:"gross_amount"`
+	DiscountAmount int                 `json:"discount_amount"`
+	Subtotal       int                 `json:"subtotal"`
+	TaxAmount      int                 `json:"tax_amount"`
+	ServiceCharge  int                 `json:"service_charge"`
+	TotalAmount    int                 `json:"total_amount"`
+	TaxInclusive   bool                `json:"tax_inclusive"`
+	Status         string              `json:"status"`
+	CashierID      *int                `json:"cashier_id"`
+	CashierName    string              `json:"cashier_name,omitempty"`
+	RegisterID     string              `json:"register_id"`
+	ShiftID        *int                `json:"shift_id"`
+	CreatedAt      time.Time           `json:"created_at"`
+	Details        []TransactionDetail `json:"details"`
+	Discounts      []AppliedDiscount   `json:"discounts"`
+	Payments       []Payment           `json:"payments"`
+	TotalPaid      int                 `json:"total_paid"`
+	Change         int                 `json:"change"`
+	// LowStock lists the products this checkout took to their reorder point.
+	LowStock []LowStockEvent `json:"-"`
 }
 
+// TransactionDetail keeps the product name, category and unit price as they were
+// at sale time, so later changes to the product do not rewrite history.
 type TransactionDetail struct {
 	ID            int    `json:"id"`
 	TransactionID int    `json:"transaction_id"`
 	ProductID     int    `json:"product_id"`
 	ProductName   string `json:"product_name,omitempty"`
-	Quantity      int    `json:"quantity"`
-	Subtotal      int    `json:"subtotal"`
+	CategoryID    *int   `json:"category_id"`
+	CategoryName  string `json:"category_name,omitempty"`
+	// Quantity and UnitPrice are in Unit, which holds UnitFactor base units.
+	Unit             string  `json:"unit"`
+	UnitFactor       int     `json:"unit_factor"`
+	UnitPrice        int     `json:"unit_price"`
+	Quantity         int     `json:"quantity"`
+	RefundedQuantity int     `json:"refunded_quantity"`
+	GrossSubtotal    int     `json:"gross_subtotal"`
+	DiscountAmount   int     `json:"discount_amount"`
+	Subtotal         int     `json:"subtotal"`
+	TaxRate          float64 `json:"tax_rate"`
+	TaxAmount        int     `json:"tax_amount"`
+	LineTotal        int     `json:"line_total"` // paid for the line, tax included
+	// UnitCost is the cost of one Unit at sale time; it is only used for margin reports.
+	UnitCost int `json:"-"`
 }
 
+// CheckoutItem names the product either by product_id or by a scanned barcode.
+// Unit is one of the product's units; empty sells in its base unit.
 type CheckoutItem struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID int    `json:"product_id"`
+	Barcode   string `json:"barcode,omitempty"`
+	Unit      string `json:"unit,omitempty"`
+	Quantity  int    `json:"quantity"`
 }
 
 type CheckoutRequest struct {
 	Items []CheckoutItem `json:"items"`
+	// Payments holds one line per tender; Payment is kept as a shorthand for a single tender.
+	Payments  []PaymentRequest `json:"payments"`
+	Payment   *PaymentRequest  `json:"payment,omitempty"`
+	PromoCode string           `json:"promo_code,omitempty"`
+	// RegisterID identifies the till and must match the cashier's open shift when given;
+	// CashierID and CashierName come from the login token.
+	RegisterID  string `json:"register_id"`
+	CashierID   int    `json:"-"`
+	CashierName string `json:"-"`
+	// CartID is set when a cart is checked out. The cart's own items and promo
+	// code are sold, the stock it reserved is available to this sale and released
+	// with it, and the cart is marked checked_out in the same DB transaction.
+	CartID int `json:"-"`
+}
+
+// TransactionFilter holds the optional filters and pagination for listing transactions.
+type TransactionFilter struct {
+	StartDate  *time.Time
+	EndDate    *time.Time
+	ProductID  int
+	MinAmount  *int
+	MaxAmount  *int
+	CashierID  int
+	RegisterID string
+	Page       int
+	Limit      int
+}
+
+type TransactionList struct {
+	Data  []Transaction `json:"data"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+	Total int           `json:"total"`
 }
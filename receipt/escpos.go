@@ -0,0 +1,30 @@
+package receipt
+
+import "bytes"
+
+var (
+	escInit    = []byte{0x1B, 0x40}       // ESC @: reset printer
+	escFeed    = []byte{0x1B, 0x64, 0x04} // ESC d 4: feed 4 lines
+	escCutPart = []byte{0x1D, 0x56, 0x01} // GS V 1: partial cut
+)
+
+// ESCPOS wraps a rendered text receipt in the commands a thermal printer needs:
+// reset, the receipt lines, a paper feed and a cut. Characters outside ASCII are
+// replaced because most printers start in a code page without them.
+func ESCPOS(text []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(escInit)
+	for _, r := range string(text) {
+		switch {
+		case r == '\n':
+			buf.WriteByte('\n')
+		case r < 0x20 || r > 0x7E:
+			buf.WriteByte('?')
+		default:
+			buf.WriteByte(byte(r))
+		}
+	}
+	buf.Write(escFeed)
+	buf.Write(escCutPart)
+	return buf.Bytes()
+}
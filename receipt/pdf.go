@@ -0,0 +1,77 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pdfFontSize  = 9.0
+	pdfLeading   = 11.0
+	pdfMargin    = 14.0
+	pdfCharWidth = pdfFontSize * 0.6 // Courier glyphs are 600/1000 em wide
+	pdfMinHeight = 100.0
+)
+
+// PDF lays the text receipt out on a single receipt-sized page in Courier, so it
+// looks the same as the printed one. It writes the PDF by hand to avoid pulling
+// in a PDF library for a monospaced page.
+func PDF(text []byte, width int) []byte {
+	lines := strings.Split(strings.TrimRight(string(text), "\n"), "\n")
+
+	pageWidth := float64(width)*pdfCharWidth + 2*pdfMargin
+	pageHeight := float64(len(lines))*pdfLeading + 2*pdfMargin
+	if pageHeight < pdfMinHeight {
+		pageHeight = pdfMinHeight
+	}
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT\n/F1 %.0f Tf\n%.0f TL\n%.2f %.2f Td\n", pdfFontSize, pdfLeading, pdfMargin, pageHeight-pdfMargin-pdfFontSize)
+	for _, line := range lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+	}
+	content.WriteString("ET\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+// pdfEscape escapes a line for a PDF literal string; non-ASCII runes become '?'.
+func pdfEscape(s string) string {
+	var out strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r < 0x20 || r > 0x7E:
+			out.WriteByte('?')
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,178 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"kasir-api/models"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	FormatText   = "text"
+	FormatESCPOS = "escpos"
+	FormatPDF    = "pdf"
+)
+
+// Config describes the store details printed on every receipt.
+type Config struct {
+	StoreName string
+	// Header lines printed under the store name, e.g. address and phone.
+	Header []string
+	Footer []string
+	// Width is the number of characters per line (32 for 58mm, 48 for 80mm paper).
+	Width int
+	// TemplatePath optionally points to a text/template file replacing DefaultTemplate.
+	TemplatePath string
+}
+
+// DefaultTemplate lays out the plain text receipt. Templates get a Data value and
+// the helpers center, row, divider, rupiah, upper and quantity.
+const DefaultTemplate = `{{center .StoreName}}
+{{range .Header}}{{center .}}
+{{end}}{{divider}}
+{{row "No" (printf "#%d" .Transaction.ID)}}
+{{row "Waktu" (.Transaction.CreatedAt.Format "02/01/2006 15:04")}}
+{{if .Transaction.CashierName}}{{row "Kasir" .Transaction.CashierName}}
+{{end}}{{if .Transaction.RegisterID}}{{row "Register" .Transaction.RegisterID}}
+{{end}}{{if ne .Transaction.Status "completed"}}{{row "Status" (upper .Transaction.Status)}}
+{{end}}{{divider}}
+{{range .Transaction.Details}}{{.ProductName}}
+{{row (printf "  %s x %s" (quantity .) (rupiah .UnitPrice)) (rupiah .GrossSubtotal)}}
+{{if .DiscountAmount}}{{row "  Diskon" (printf "-%s" (rupiah .DiscountAmount))}}
+{{end}}{{end}}{{divider}}
+{{if .Transaction.DiscountAmount}}{{row "Total Diskon" (printf "-%s" (rupiah .Transaction.DiscountAmount))}}
+{{end}}{{row "Subtotal" (rupiah .Transaction.Subtotal)}}
+{{if .Transaction.TaxInclusive}}{{row "PPN (termasuk)" (rupiah .Transaction.TaxAmount)}}{{else}}{{row "PPN" (rupiah .Transaction.TaxAmount)}}{{end}}
+{{if .Transaction.ServiceCharge}}{{row "Service" (rupiah .Transaction.ServiceCharge)}}
+{{end}}{{row "TOTAL" (rupiah .Transaction.TotalAmount)}}
+{{divider}}
+{{range .Transaction.Payments}}{{row (upper .Method) (rupiah .AmountTendered)}}
+{{end}}{{row "Kembali" (rupiah .Transaction.Change)}}
+{{divider}}
+{{range .Footer}}{{center .}}
+{{end}}`
+
+// Data is what a receipt template is executed with.
+type Data struct {
+	StoreName   string
+	Header      []string
+	Footer      []string
+	Transaction *models.Transaction
+}
+
+type Renderer struct {
+	cfg  Config
+	tmpl *template.Template
+}
+
+func NewRenderer(cfg Config) (*Renderer, error) {
+	if cfg.Width <= 0 {
+		cfg.Width = 32
+	}
+
+	text := DefaultTemplate
+	if cfg.TemplatePath != "" {
+		content, err := os.ReadFile(cfg.TemplatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read receipt template: %w", err)
+		}
+		text = string(content)
+	}
+
+	tmpl, err := template.New("receipt").Funcs(funcs(cfg.Width)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse receipt template: %w", err)
+	}
+
+	return &Renderer{cfg: cfg, tmpl: tmpl}, nil
+}
+
+// Render produces the receipt in the given format and returns it with its content type.
+func (r *Renderer) Render(t *models.Transaction, format string) ([]byte, string, error) {
+	switch format {
+	case "", FormatText:
+		text, err := r.Text(t)
+		return text, "text/plain; charset=utf-8", err
+	case FormatESCPOS:
+		text, err := r.Text(t)
+		if err != nil {
+			return nil, "", err
+		}
+		return ESCPOS(text), "application/octet-stream", nil
+	case FormatPDF:
+		text, err := r.Text(t)
+		if err != nil {
+			return nil, "", err
+		}
+		return PDF(text, r.cfg.Width), "application/pdf", nil
+	}
+	return nil, "", fmt.Errorf("invalid receipt format %q (text, escpos, pdf)", format)
+}
+
+// Text renders the plain text receipt.
+func (r *Renderer) Text(t *models.Transaction) ([]byte, error) {
+	var buf bytes.Buffer
+	err := r.tmpl.Execute(&buf, Data{
+		StoreName:   r.cfg.StoreName,
+		Header:      r.cfg.Header,
+		Footer:      r.cfg.Footer,
+		Transaction: t,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func funcs(width int) template.FuncMap {
+	return template.FuncMap{
+		"center": func(s string) string {
+			pad := (width - len([]rune(s))) / 2
+			if pad <= 0 {
+				return s
+			}
+			return strings.Repeat(" ", pad) + s
+		},
+		"row": func(left, right string) string {
+			gap := width - len([]rune(left)) - len([]rune(right))
+			if gap < 1 {
+				gap = 1
+			}
+			return left + strings.Repeat(" ", gap) + right
+		},
+		"divider": func() string {
+			return strings.Repeat("-", width)
+		},
+		"rupiah": Rupiah,
+		"upper":  strings.ToUpper,
+		// quantity names the unit for packs, e.g. "2 dus", and not for base units
+		"quantity": func(d models.TransactionDetail) string {
+			if d.UnitFactor > 1 {
+				return fmt.Sprintf("%d %s", d.Quantity, d.Unit)
+			}
+			return strconv.Itoa(d.Quantity)
+		},
+	}
+}
+
+// Rupiah formats an amount with dot thousand separators, e.g. 12000 -> "12.000".
+func Rupiah(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.Itoa(amount)
+	var out strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out.WriteByte('.')
+		}
+		out.WriteRune(c)
+	}
+	return sign + out.String()
+}
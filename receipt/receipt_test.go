@@ -0,0 +1,91 @@
+package receipt
+
+import (
+	"bytes"
+	"kasir-api/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleTransaction() *models.Transaction {
+	return &models.Transaction{
+		ID:             42,
+		GrossAmount:    19000,
+		DiscountAmount: 1000,
+		Subtotal:       18000,
+		TaxAmount:      1980,
+		TotalAmount:    19980,
+		Status:         models.TransactionStatusCompleted,
+		CreatedAt:      time.Date(2026, 1, 2, 13, 4, 0, 0, time.Local),
+		Details: []models.TransactionDetail{
+			{ProductName: "Indomie", UnitPrice: 3500, Quantity: 2, GrossSubtotal: 7000, Subtotal: 7000},
+			{ProductName: "Kecap", Unit: "pak", UnitFactor: 2, UnitPrice: 12000, Quantity: 1, GrossSubtotal: 12000, DiscountAmount: 1000, Subtotal: 11000},
+		},
+		Payments: []models.Payment{{Method: "cash", AmountTendered: 20000, Change: 20}},
+		Change:   20,
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	r, err := NewRenderer(Config{StoreName: "Toko Maju", Footer: []string{"Terima kasih"}, Width: 32})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	out, contentType, err := r.Render(sampleTransaction(), FormatText)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+
+	text := string(out)
+	for _, want := range []string{"Toko Maju", "#42", "2 x 3.500", "1 pak x 12.000", "-1.000", "19.980", "CASH", "Terima kasih"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected receipt to contain %q, got:\n%s", want, text)
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if len([]rune(line)) > 32 {
+			t.Errorf("line wider than paper: %q", line)
+		}
+	}
+}
+
+func TestRenderESCPOSAndPDF(t *testing.T) {
+	r, err := NewRenderer(Config{StoreName: "Toko Maju"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	escpos, _, err := r.Render(sampleTransaction(), FormatESCPOS)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.HasPrefix(escpos, escInit) || !bytes.HasSuffix(escpos, escCutPart) {
+		t.Error("expected ESC/POS output to start with init and end with cut")
+	}
+
+	pdf, contentType, err := r.Render(sampleTransaction(), FormatPDF)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if contentType != "application/pdf" || !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.Contains(pdf, []byte("%%EOF")) {
+		t.Error("expected a complete PDF document")
+	}
+
+	if _, _, err := r.Render(sampleTransaction(), "html"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestRupiah(t *testing.T) {
+	cases := map[int]string{0: "0", 500: "500", 3500: "3.500", 1250000: "1.250.000", -12000: "-12.000"}
+	for in, want := range cases {
+		if got := Rupiah(in); got != want {
+			t.Errorf("Rupiah(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"kasir-api/models"
+	"time"
+)
+
+type CartRepository struct {
+	db  *sql.DB
+	tax models.TaxConfig
+}
+
+func NewCartRepository(db *sql.DB, tax models.TaxConfig) *CartRepository {
+	return &CartRepository{db: db, tax: tax}
+}
+
+const cartSelect = `SELECT c.id, c.name, c.status, c.promo_code, c.created_by, COALESCE(u.username, ''), c.transaction_id,
+(SELECT MIN(r.expires_at) FROM stock_reservations r WHERE r.cart_id = c.id AND r.expires_at > NOW()), c.created_at, c.updated_at
+FROM carts c
+LEFT JOIN users u ON c.created_by = u.id`
+
+func scanCart(scanner interface{ Scan(...any) error }, c *models.Cart) error {
+	return scanner.Scan(&c.ID, &c.Name, &c.Status, &c.PromoCode, &c.CreatedBy, &c.CreatedByName, &c.TransactionID,
+		&c.ReservedUntil, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// GetAll lists the carts without their items, most recently changed first.
+func (repo *CartRepository) GetAll(status string) ([]models.Cart, error) {
+	query := cartSelect
+	args := make([]any, 0)
+	if status != "" {
+		query += " WHERE c.status = $1"
+		args = append(args, status)
+	}
+
+	rows, err := repo.db.Query(query+" ORDER BY c.updated_at DESC, c.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	carts := make([]models.Cart, 0)
+	for rows.Next() {
+		var c models.Cart
+		if err := scanCart(rows, &c); err != nil {
+			return nil, err
+		}
+		carts = append(carts, c)
+	}
+
+	return carts, rows.Err()
+}
+
+// GetByID returns the cart with its items and totals at current prices.
+func (repo *CartRepository) GetByID(id int) (*models.Cart, error) {
+	var c models.Cart
+	err := scanCart(repo.db.QueryRow(cartSelect+" WHERE c.id = $1", id), &c)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("cart id %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repo.price(repo.db, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (repo *CartRepository) Create(c *models.Cart) error {
+	c.Status = models.CartStatusOpen
+	c.UpdatedAt = c.CreatedAt
+	return repo.db.QueryRow("INSERT INTO carts (name, status, promo_code, created_by, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		c.Name, c.Status, c.PromoCode, c.CreatedBy, c.CreatedAt, c.UpdatedAt).Scan(&c.ID)
+}
+
+// Update renames the cart and sets its promo code, keeping whichever is nil.
+func (repo *CartRepository) Update(id int, req models.CartUpdateRequest) error {
+	return repo.change(id, func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE carts SET name = COALESCE($1, name), promo_code = COALESCE($2, promo_code) WHERE id = $3", req.Name, req.PromoCode, id)
+		return err
+	})
+}
+
+// AddItem puts a product in the cart, or adds to its quantity when it is there
+// already in the same unit.
+func (repo *CartRepository) AddItem(id int, req models.CartItemRequest) error {
+	return repo.change(id, func(tx *sql.Tx) error {
+		items, err := resolveCheckoutItems(tx, []models.CheckoutItem{{ProductID: req.ProductID, Barcode: req.Barcode, Quantity: req.Quantity}})
+		if err != nil {
+			return err
+		}
+		productID := items[0].ProductID
+
+		var name string
+		var archived bool
+		err = tx.QueryRow("SELECT name, archived_at IS NOT NULL FROM products WHERE id = $1", productID).Scan(&name, &archived)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("product id %d not found", productID)
+		}
+		if err != nil {
+			return err
+		}
+		if archived {
+			return fmt.Errorf("product %s (id: %d) is archived and cannot be sold", name, productID)
+		}
+		unit, err := cartUnit(tx, productID, req.Unit)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO cart_items (cart_id, product_id, unit, quantity) VALUES ($1, $2, $3, $4)
+ON CONFLICT (cart_id, product_id, unit) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity`, id, productID, unit, req.Quantity)
+		return err
+	})
+}
+
+// SetItemQuantity changes the quantity of a product in the given unit in the
+// cart; 0 removes it.
+func (repo *CartRepository) SetItemQuantity(id, productID int, unit string, quantity int) error {
+	return repo.change(id, func(tx *sql.Tx) error {
+		unit, err := cartUnit(tx, productID, unit)
+		if err != nil {
+			return err
+		}
+
+		var result sql.Result
+		if quantity == 0 {
+			result, err = tx.Exec("DELETE FROM cart_items WHERE cart_id = $1 AND product_id = $2 AND unit = $3", id, productID, unit)
+		} else {
+			result, err = tx.Exec("UPDATE cart_items SET quantity = $1 WHERE cart_id = $2 AND product_id = $3 AND unit = $4", quantity, id, productID, unit)
+		}
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("product id %d not found in cart %d", productID, id)
+		}
+		return nil
+	})
+}
+
+// cartUnit is how a cart line stores unit: "" for the product's base unit, or
+// the name of one of its packs.
+func cartUnit(q queryer, productID int, unit string) (string, error) {
+	if unit == "" {
+		return "", nil
+	}
+
+	var baseUnit string
+	var isPack bool
+	err := q.QueryRow("SELECT p.base_unit, EXISTS (SELECT 1 FROM product_units u WHERE u.product_id = p.id AND u.unit = $2) FROM products p WHERE p.id = $1",
+		productID, unit).Scan(&baseUnit, &isPack)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("product id %d not found", productID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case unit == baseUnit:
+		return "", nil
+	case isPack:
+		return unit, nil
+	}
+	return "", fmt.Errorf("invalid unit %q for product id %d", unit, productID)
+}
+
+// change runs fn on a cart that can still be edited and bumps its updated_at.
+func (repo *CartRepository) change(id int, fn func(tx *sql.Tx) error) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockCart(tx, id)
+	if err != nil {
+		return err
+	}
+	if status != models.CartStatusOpen {
+		return fmt.Errorf("cart id %d is %s, only open carts can be changed", id, status)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE carts SET updated_at = $1 WHERE id = $2", models.GetCurrentTime(), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Park puts an open cart aside and reserves the stock of its items until
+// expiresAt, so it cannot be sold to someone else in the meantime.
+func (repo *CartRepository) Park(id int, expiresAt time.Time) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockCart(tx, id)
+	if err != nil {
+		return err
+	}
+	if status == models.CartStatusParked {
+		return fmt.Errorf("cart id %d is already %s", id, status)
+	}
+	if status != models.CartStatusOpen {
+		return fmt.Errorf("cart id %d is %s and cannot become %s", id, status, models.CartStatusParked)
+	}
+
+	// Reservations are per product in base units, whatever packs the cart holds
+	rows, err := tx.Query(`SELECT ci.product_id, SUM(ci.quantity * COALESCE(u.factor, 1))
+FROM cart_items ci
+LEFT JOIN product_units u ON u.product_id = ci.product_id AND u.unit = ci.unit
+WHERE ci.cart_id = $1
+GROUP BY ci.product_id`, id)
+	if err != nil {
+		return err
+	}
+	items := make([]models.CheckoutItem, 0)
+	for rows.Next() {
+		var item models.CheckoutItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM stock_reservations WHERE cart_id = $1", id); err != nil {
+		return err
+	}
+
+	// Locked in the same product order as checkout, so parking and selling the
+	// same products cannot deadlock
+	now := models.GetCurrentTime()
+	for _, i := range lockOrder(items) {
+		item := items[i]
+		var name string
+		var stock int
+		err := tx.QueryRow("SELECT name, stock FROM products WHERE id = $1 FOR UPDATE", item.ProductID).Scan(&name, &stock)
+		if err != nil {
+			return err
+		}
+		reserved, err := reservedStock(tx, item.ProductID, id)
+		if err != nil {
+			return err
+		}
+		if available := availableStock(stock, reserved); available < item.Quantity {
+			return fmt.Errorf("product %s (id: %d) has insufficient stock to reserve, %d available", name, item.ProductID, available)
+		}
+
+		_, err = tx.Exec("INSERT INTO stock_reservations (cart_id, product_id, quantity, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)",
+			id, item.ProductID, item.Quantity, expiresAt, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE carts SET status = $1, updated_at = $2 WHERE id = $3", models.CartStatusParked, now, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetStatus moves the cart to status when it is currently in one of from. Only
+// parked carts hold stock, so any reservation of the cart is released.
+func (repo *CartRepository) SetStatus(id int, status string, from ...string) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := lockCart(tx, id)
+	if err != nil {
+		return err
+	}
+	if current == status {
+		return fmt.Errorf("cart id %d is already %s", id, status)
+	}
+	allowed := false
+	for _, f := range from {
+		allowed = allowed || current == f
+	}
+	if !allowed {
+		return fmt.Errorf("cart id %d is %s and cannot become %s", id, current, status)
+	}
+
+	_, err = tx.Exec("UPDATE carts SET status = $1, updated_at = $2 WHERE id = $3", status, models.GetCurrentTime(), id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM stock_reservations WHERE cart_id = $1", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// price loads the cart items and works out their totals the way checkout would
+// right now, packs at their own price. A promo code that does not apply is
+// reported in PromoError and left out of the totals.
+func (repo *CartRepository) price(q queryer, c *models.Cart) error {
+	rows, err := q.Query(`SELECT ci.id, ci.product_id, p.name, COALESCE(NULLIF(ci.unit, ''), p.base_unit), COALESCE(u.factor, 1), COALESCE(u.price, p.price),
+p.stock, COALESCE(p.category_id, 0), p.tax_exempt, cat.tax_rate, ci.quantity
+FROM cart_items ci
+JOIN products p ON ci.product_id = p.id
+LEFT JOIN product_units u ON u.product_id = ci.product_id AND u.unit = ci.unit
+LEFT JOIN categories cat ON p.category_id = cat.id
+WHERE ci.cart_id = $1
+ORDER BY ci.id`, c.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	c.Items = make([]models.CartItem, 0)
+	lines := make([]checkoutLine, 0)
+	for rows.Next() {
+		var item models.CartItem
+		var categoryID int
+		var taxExempt bool
+		var categoryTaxRate *float64
+		err := rows.Scan(&item.ID, &item.ProductID, &item.ProductName, &item.Unit, &item.UnitFactor, &item.UnitPrice, &item.Stock, &categoryID,
+			&taxExempt, &categoryTaxRate, &item.Quantity)
+		if err != nil {
+			return err
+		}
+		c.Items = append(c.Items, item)
+		lines = append(lines, checkoutLine{
+			ProductID:  item.ProductID,
+			CategoryID: categoryID,
+			Quantity:   item.Quantity * item.UnitFactor,
+			Gross:      item.UnitPrice * item.Quantity,
+			TaxRate:    lineTaxRate(repo.tax, taxExempt, categoryTaxRate),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := models.GetCurrentTime()
+	discounts, err := activeDiscounts(q, now, c.PromoCode)
+	if err != nil {
+		return err
+	}
+	if _, err := applyDiscounts(lines, discounts, c.PromoCode); err != nil {
+		if c.PromoCode == "" {
+			return err
+		}
+		c.PromoError = err.Error()
+		for i := range lines {
+			lines[i].Discount = 0
+		}
+		if discounts, err = activeDiscounts(q, now, ""); err != nil {
+			return err
+		}
+		if _, err := applyDiscounts(lines, discounts, ""); err != nil {
+			return err
+		}
+	}
+
+	c.Subtotal, c.TaxAmount, c.ServiceCharge = applyTax(lines, repo.tax)
+	c.GrossAmount, c.DiscountAmount = 0, 0
+	for i, line := range lines {
+		c.Items[i].GrossSubtotal = line.Gross
+		c.Items[i].DiscountAmount = line.Discount
+		c.Items[i].TaxAmount = line.Tax
+		c.Items[i].LineTotal = line.Total
+		c.GrossAmount += line.Gross
+		c.DiscountAmount += line.Discount
+	}
+	c.TotalAmount = c.Subtotal + c.TaxAmount + c.ServiceCharge
+
+	return nil
+}
+
+// ExpireReservations deletes the reservations whose time is up and returns how
+// many there were. Expired reservations stop counting straight away; this only
+// clears them out.
+func (repo *CartRepository) ExpireReservations() (int64, error) {
+	result, err := repo.db.Exec("DELETE FROM stock_reservations WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// reservedStock is the quantity of the product held for carts other than cartID.
+func reservedStock(q queryer, productID, cartID int) (int, error) {
+	var reserved int
+	err := q.QueryRow("SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE product_id = $1 AND cart_id <> $2 AND expires_at > NOW()",
+		productID, cartID).Scan(&reserved)
+	return reserved, err
+}
+
+// checkoutCart locks an open or parked cart inside a checkout transaction and
+// returns its items and promo code, so the sale is the cart as it is when the
+// checkout commits.
+func checkoutCart(tx *sql.Tx, id int) ([]models.CheckoutItem, string, error) {
+	var status, promoCode string
+	err := tx.QueryRow("SELECT status, promo_code FROM carts WHERE id = $1 FOR UPDATE", id).Scan(&status, &promoCode)
+	if err == sql.ErrNoRows {
+		return nil, "", fmt.Errorf("cart id %d not found", id)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if status != models.CartStatusOpen && status != models.CartStatusParked {
+		return nil, "", fmt.Errorf("cart id %d is already %s", id, status)
+	}
+
+	rows, err := tx.Query("SELECT product_id, unit, quantity FROM cart_items WHERE cart_id = $1 ORDER BY id", id)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	items := make([]models.CheckoutItem, 0)
+	for rows.Next() {
+		var item models.CheckoutItem
+		if err := rows.Scan(&item.ProductID, &item.Unit, &item.Quantity); err != nil {
+			return nil, "", err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	if len(items) == 0 {
+		return nil, "", fmt.Errorf("cart id %d has no items", id)
+	}
+
+	return items, promoCode, nil
+}
+
+// lockCart locks the cart row for the rest of tx and returns its status.
+func lockCart(tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRow("SELECT status FROM carts WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("cart id %d not found", id)
+	}
+	return status, err
+}
@@ -0,0 +1,271 @@
+package repositories
+
+import (
+	"kasir-api/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var (
+	cartColumns     = []string{"id", "name", "status", "promo_code", "created_by", "username", "transaction_id", "reserved_until", "created_at", "updated_at"}
+	cartItemColumns = []string{"id", "product_id", "name", "unit", "unit_factor", "price", "stock", "category_id", "tax_exempt", "tax_rate", "quantity"}
+	discountRows    = []string{"id", "name", "type", "value", "scope", "product_id", "category_id", "promo_code", "min_purchase", "starts_at", "ends_at", "active"}
+)
+
+func TestGetCart_PricesItemsAndReportsUnusablePromo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewCartRepository(db, models.TaxConfig{Rate: 11})
+	now := time.Now()
+
+	mock.ExpectQuery("FROM carts c").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(cartColumns).AddRow(4, "Meja 3", models.CartStatusParked, "HEMAT20", 2, "kasir1", nil, nil, now, now))
+	mock.ExpectQuery("FROM cart_items ci").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(cartItemColumns).
+			AddRow(1, 1, "Indomie", "pcs", 1, 3000, 10, 1, false, nil, 2).
+			AddRow(2, 2, "Vit 1000ml", "btl", 1, 4000, 5, 2, false, nil, 1))
+
+	// The promo needs a bigger purchase, so only the automatic discount applies
+	mock.ExpectQuery("FROM discounts").
+		WithArgs(sqlmock.AnyArg(), "HEMAT20").
+		WillReturnRows(sqlmock.NewRows(discountRows).
+			AddRow(1, "Indomie 500", models.DiscountTypeFixed, 500, models.DiscountScopeProduct, 1, nil, nil, 0, nil, nil, true).
+			AddRow(2, "Promo", models.DiscountTypePercentage, 20, models.DiscountScopeCart, nil, nil, "HEMAT20", 50000, nil, nil, true))
+	mock.ExpectQuery("FROM discounts").
+		WithArgs(sqlmock.AnyArg(), "").
+		WillReturnRows(sqlmock.NewRows(discountRows).
+			AddRow(1, "Indomie 500", models.DiscountTypeFixed, 500, models.DiscountScopeProduct, 1, nil, nil, 0, nil, nil, true))
+
+	cart, err := repo.GetByID(4)
+	if err != nil {
+		t.Fatalf("error was not expected while getting cart: %s", err)
+	}
+
+	if cart.PromoError == "" {
+		t.Errorf("expected the unusable promo code to be reported")
+	}
+	if len(cart.Items) != 2 || cart.Items[0].DiscountAmount != 1000 || cart.Items[1].DiscountAmount != 0 {
+		t.Errorf("unexpected item discounts: %+v", cart.Items)
+	}
+	if cart.GrossAmount != 10000 || cart.DiscountAmount != 1000 || cart.Subtotal != 9000 || cart.TaxAmount != 990 || cart.TotalAmount != 9990 {
+		t.Errorf("unexpected totals: gross %d, discount %d, subtotal %d, tax %d, total %d",
+			cart.GrossAmount, cart.DiscountAmount, cart.Subtotal, cart.TaxAmount, cart.TotalAmount)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAddCartItem_OnlyOpenCarts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewCartRepository(db, models.TaxConfig{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM carts").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.CartStatusParked))
+	mock.ExpectRollback()
+
+	err = repo.AddItem(4, models.CartItemRequest{ProductID: 1, Quantity: 1})
+	if err == nil || !strings.Contains(err.Error(), "only open carts") {
+		t.Fatalf("expected only open carts error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateCart_KeepsOmittedPromoCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewCartRepository(db, models.TaxConfig{})
+
+	name := "Meja 5"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM carts").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.CartStatusOpen))
+	mock.ExpectExec("UPDATE carts SET name = COALESCE\\(\\$1, name\\), promo_code = COALESCE\\(\\$2, promo_code\\) WHERE id = \\$3").
+		WithArgs(name, nil, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE carts SET updated_at").
+		WithArgs(sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.Update(4, models.CartUpdateRequest{Name: &name}); err != nil {
+		t.Fatalf("error was not expected while updating cart: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetCart_PricesPacksAtTheirOwnPrice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewCartRepository(db, models.TaxConfig{})
+	now := time.Now()
+
+	mock.ExpectQuery("FROM carts c").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(cartColumns).AddRow(4, "Grosir", models.CartStatusOpen, "", 2, "kasir1", nil, nil, now, now))
+	mock.ExpectQuery("LEFT JOIN product_units u ON u.product_id = ci.product_id AND u.unit = ci.unit").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(cartItemColumns).
+			AddRow(1, 1, "Indomie", "dus", 40, 110000, 100, 1, false, nil, 2).
+			AddRow(2, 1, "Indomie", "pcs", 1, 3500, 100, 1, false, nil, 3))
+	mock.ExpectQuery("FROM discounts").
+		WithArgs(sqlmock.AnyArg(), "").
+		WillReturnRows(sqlmock.NewRows(discountRows))
+
+	cart, err := repo.GetByID(4)
+	if err != nil {
+		t.Fatalf("error was not expected while getting cart: %s", err)
+	}
+
+	if len(cart.Items) != 2 || cart.Items[0].Unit != "dus" || cart.Items[0].GrossSubtotal != 220000 || cart.Items[1].GrossSubtotal != 10500 {
+		t.Errorf("expected 2 dus and 3 pcs priced per unit, got %+v", cart.Items)
+	}
+	if cart.TotalAmount != 230500 {
+		t.Errorf("expected total 230500, got %d", cart.TotalAmount)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAddCartItem_RejectsUnknownUnit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewCartRepository(db, models.TaxConfig{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM carts").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.CartStatusOpen))
+	mock.ExpectQuery("SELECT name, archived_at IS NOT NULL FROM products").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "archived"}).AddRow("Indomie", false))
+	mock.ExpectQuery("SELECT p.base_unit, EXISTS").
+		WithArgs(1, "pak").
+		WillReturnRows(sqlmock.NewRows([]string{"base_unit", "is_pack"}).AddRow("pcs", false))
+	mock.ExpectRollback()
+
+	err = repo.AddItem(4, models.CartItemRequest{ProductID: 1, Unit: "pak", Quantity: 1})
+	if err == nil || err.Error() != `invalid unit "pak" for product id 1` {
+		t.Fatalf("expected invalid unit error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestParkCart_ReservesStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewCartRepository(db, models.TaxConfig{})
+	expiresAt := time.Now().Add(30 * time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM carts").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.CartStatusOpen))
+	mock.ExpectQuery("FROM cart_items ci LEFT JOIN product_units u").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(2, 1).AddRow(1, 2))
+	mock.ExpectExec("DELETE FROM stock_reservations WHERE cart_id = \\$1").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Products are locked in ID order, whatever the order of the cart
+	mock.ExpectQuery("SELECT name, stock FROM products WHERE id = \\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "stock"}).AddRow("Indomie", 10))
+	mock.ExpectQuery("FROM stock_reservations WHERE product_id").
+		WithArgs(1, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(8))
+	mock.ExpectExec("INSERT INTO stock_reservations").
+		WithArgs(4, 1, 2, expiresAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT name, stock FROM products").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "stock"}).AddRow("Vit 1000ml", 5))
+	mock.ExpectQuery("FROM stock_reservations WHERE product_id").
+		WithArgs(2, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO stock_reservations").
+		WithArgs(4, 2, 1, expiresAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("UPDATE carts SET status = \\$1, updated_at = \\$2 WHERE id = \\$3").
+		WithArgs(models.CartStatusParked, sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.Park(4, expiresAt); err != nil {
+		t.Fatalf("error was not expected while parking cart: %s", err)
+	}
+
+	// Another cart cannot reserve what is already held
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM carts").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.CartStatusOpen))
+	mock.ExpectQuery("FROM cart_items ci LEFT JOIN product_units u").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow(1, 1))
+	mock.ExpectExec("DELETE FROM stock_reservations").
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT name, stock FROM products").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "stock"}).AddRow("Indomie", 10))
+	mock.ExpectQuery("FROM stock_reservations WHERE product_id").
+		WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(10))
+	mock.ExpectRollback()
+
+	err = repo.Park(5, expiresAt)
+	if err == nil || !strings.Contains(err.Error(), "insufficient stock to reserve, 0 available") {
+		t.Fatalf("expected insufficient stock to reserve, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -14,8 +14,12 @@ func NewCategoryRepository(db *sql.DB) *CategoryRepository {
 	return &CategoryRepository{db: db}
 }
 
-func (repo *CategoryRepository) GetAll() ([]models.Category, error) {
-	query := "SELECT id, name, description FROM categories"
+// GetAll lists the categories; archived ones only when includeArchived is set.
+func (repo *CategoryRepository) GetAll(includeArchived bool) ([]models.Category, error) {
+	query := "SELECT id, name, description, tax_rate, archived_at FROM categories"
+	if !includeArchived {
+		query += " WHERE archived_at IS NULL"
+	}
 	rows, err := repo.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -25,7 +29,7 @@ func (repo *CategoryRepository) GetAll() ([]models.Category, error) {
 	categories := make([]models.Category, 0)
 	for rows.Next() {
 		var c models.Category
-		err := rows.Scan(&c.ID, &c.Name, &c.Description)
+		err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.TaxRate, &c.ArchivedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -36,10 +40,10 @@ func (repo *CategoryRepository) GetAll() ([]models.Category, error) {
 }
 
 func (repo *CategoryRepository) GetByID(id int) (*models.Category, error) {
-	query := "SELECT id, name, description FROM categories WHERE id = $1"
+	query := "SELECT id, name, description, tax_rate, archived_at FROM categories WHERE id = $1"
 
 	var c models.Category
-	err := repo.db.QueryRow(query, id).Scan(&c.ID, &c.Name, &c.Description)
+	err := repo.db.QueryRow(query, id).Scan(&c.ID, &c.Name, &c.Description, &c.TaxRate, &c.ArchivedAt)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("kategori tidak ditemukan")
 	}
@@ -51,14 +55,14 @@ func (repo *CategoryRepository) GetByID(id int) (*models.Category, error) {
 }
 
 func (repo *CategoryRepository) Create(category *models.Category) error {
-	query := "INSERT INTO categories (name, description) VALUES ($1, $2) RETURNING id"
-	err := repo.db.QueryRow(query, category.Name, category.Description).Scan(&category.ID)
+	query := "INSERT INTO categories (name, description, tax_rate) VALUES ($1, $2, $3) RETURNING id"
+	err := repo.db.QueryRow(query, category.Name, category.Description, category.TaxRate).Scan(&category.ID)
 	return err
 }
 
 func (repo *CategoryRepository) Update(category *models.Category) error {
-	query := "UPDATE categories SET name = $1, description = $2 WHERE id = $3"
-	result, err := repo.db.Exec(query, category.Name, category.Description, category.ID)
+	query := "UPDATE categories SET name = $1, description = $2, tax_rate = $3 WHERE id = $4"
+	result, err := repo.db.Exec(query, category.Name, category.Description, category.TaxRate, category.ID)
 	if err != nil {
 		return err
 	}
@@ -75,8 +79,9 @@ func (repo *CategoryRepository) Update(category *models.Category) error {
 	return nil
 }
 
+// Delete archives the category; its products keep their category.
 func (repo *CategoryRepository) Delete(id int) error {
-	query := "DELETE FROM categories WHERE id = $1"
+	query := "UPDATE categories SET archived_at = COALESCE(archived_at, NOW()) WHERE id = $1"
 	result, err := repo.db.Exec(query, id)
 	if err != nil {
 		return err
@@ -93,3 +98,22 @@ func (repo *CategoryRepository) Delete(id int) error {
 
 	return nil
 }
+
+// Restore brings an archived category back.
+func (repo *CategoryRepository) Restore(id int) error {
+	result, err := repo.db.Exec("UPDATE categories SET archived_at = NULL WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.New("kategori tidak ditemukan")
+	}
+
+	return nil
+}
@@ -0,0 +1,306 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/database"
+	"kasir-api/migrations"
+	"kasir-api/models"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestRetryOnConflict(t *testing.T) {
+	defer func(s func(time.Duration)) { sleep = s }(sleep)
+	waits := make([]time.Duration, 0)
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	deadlock := &pq.Error{Code: "40P01"}
+	calls := 0
+	err := retryOnConflict(func() error {
+		calls++
+		if calls < 3 {
+			return deadlock
+		}
+		return nil
+	})
+	if err != nil || calls != 3 || len(waits) != 2 {
+		t.Errorf("expected success on the third attempt after two waits, got %v after %d calls and %d waits", err, calls, len(waits))
+	}
+
+	calls = 0
+	err = retryOnConflict(func() error {
+		calls++
+		return &pq.Error{Code: "40001"}
+	})
+	if !errors.As(err, new(*pq.Error)) || calls != conflictRetries+1 {
+		t.Errorf("expected to give up after %d attempts, got %v after %d calls", conflictRetries+1, err, calls)
+	}
+
+	calls = 0
+	err = retryOnConflict(func() error {
+		calls++
+		return fmt.Errorf("product id 9 not found")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("expected other errors to be returned at once, got %v after %d calls", err, calls)
+	}
+}
+
+func TestCreateTransaction_LocksInProductOrderAndMergesLines(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+	req := models.CheckoutRequest{
+		Items: []models.CheckoutItem{
+			{ProductID: 2, Quantity: 1},
+			{Barcode: "8992388101016", Quantity: 1},
+			{ProductID: 2, Quantity: 2},
+		},
+		Payments:  []models.PaymentRequest{{Method: models.PaymentMethodCash, AmountTendered: 20000}},
+		CashierID: 3,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, register_id FROM shifts").
+		WithArgs(3, models.ShiftStatusOpen).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "register_id"}).AddRow(5, "KASIR-1"))
+	mock.ExpectQuery("SELECT product_id FROM product_barcodes WHERE code = \\$1").
+		WithArgs("8992388101016").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}).AddRow(1))
+
+	// Product 1 is locked first although it comes second in the cart
+	mock.ExpectQuery("FROM products p .* FOR UPDATE OF p").
+		WithArgs(1, "").
+		WillReturnRows(sqlmock.NewRows(checkoutProductColumns).AddRow("Indomie", 3500, 2800, 10, 1, "Makanan", false, nil, nil, 0, false, "pcs", nil, nil))
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1").
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(9))
+	mock.ExpectQuery("FROM stock_reservations").
+		WithArgs(1, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(0))
+	mock.ExpectQuery("FROM products p .* FOR UPDATE OF p").
+		WithArgs(2, "").
+		WillReturnRows(sqlmock.NewRows(checkoutProductColumns).AddRow("Vit 1000ml", 4000, 2000, 10, 2, "Minuman", false, nil, nil, 0, false, "btl", nil, nil))
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1").
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(7))
+	mock.ExpectQuery("FROM stock_reservations").
+		WithArgs(2, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(0))
+
+	mock.ExpectQuery("FROM discounts").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "type", "value", "scope", "product_id", "category_id", "promo_code", "min_purchase", "starts_at", "ends_at", "active"}))
+	mock.ExpectQuery("INSERT INTO transactions").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	// Details keep the cart order, with the two lines of product 2 merged
+	mock.ExpectQuery("INSERT INTO transaction_details").
+		WithArgs(1, 2, "Vit 1000ml", 2, "Minuman", "btl", 1, 4000, 3, 12000, 0, 12000, 0.0, 0, 12000, 2000).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO transaction_details").
+		WithArgs(1, 1, "Indomie", 1, "Makanan", "pcs", 1, 3500, 1, 3500, 0, 3500, 0.0, 0, 3500, 2800).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("INSERT INTO payments").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	tx, err := repo.CreateTransaction(req, true)
+	if err != nil {
+		t.Fatalf("error was not expected while creating transaction: %s", err)
+	}
+	if len(tx.Details) != 2 || tx.TotalAmount != 15500 {
+		t.Errorf("expected two merged lines totalling 15500, got %d lines totalling %d", len(tx.Details), tx.TotalAmount)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestCheckout_NoOversellUnderConcurrency runs against a real, disposable
+// Postgres database given in TEST_DATABASE_URL and is skipped without one.
+// Carts buy the same two products in opposite orders, with and without
+// ?lock=true; only as many may succeed as there is stock, and none may fail
+// with a deadlock.
+func TestCheckout_NoOversellUnderConcurrency(t *testing.T) {
+	db, cashier, suffix := openConcurrencyDB(t)
+	defer db.Close()
+
+	const stock, buyers = 10, 40
+	products := NewProductRepository(db)
+	a := models.Product{Name: fmt.Sprintf("Concurrency A %d", suffix), Price: 1000, Stock: stock}
+	b := models.Product{Name: fmt.Sprintf("Concurrency B %d", suffix), Price: 1000, Stock: stock}
+	for _, p := range []*models.Product{&a, &b} {
+		if err := products.Create(p, cashier.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sold := 0
+	for i := 0; i < buyers; i++ {
+		items := []models.CheckoutItem{{ProductID: a.ID, Quantity: 1}, {ProductID: b.ID, Quantity: 1}}
+		if i%2 == 1 {
+			items[0], items[1] = items[1], items[0]
+		}
+		wg.Add(1)
+		go func(useLock bool) {
+			defer wg.Done()
+			_, err := repo.CreateTransaction(models.CheckoutRequest{
+				Items:     items,
+				Payments:  []models.PaymentRequest{{Method: models.PaymentMethodCash, AmountTendered: 2000}},
+				CashierID: cashier.ID,
+			}, useLock)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				sold++
+			} else if !strings.Contains(err.Error(), "insufficient stock") {
+				t.Errorf("unexpected checkout error: %v", err)
+			}
+		}(i%4 < 2)
+	}
+	wg.Wait()
+
+	if sold != stock {
+		t.Errorf("expected exactly %d checkouts to succeed, got %d", stock, sold)
+	}
+	for _, p := range []models.Product{a, b} {
+		var left, ledger int
+		if err := db.QueryRow("SELECT stock FROM products WHERE id = $1", p.ID).Scan(&left); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.QueryRow("SELECT COALESCE(SUM(quantity), 0) FROM stock_movements WHERE product_id = $1", p.ID).Scan(&ledger); err != nil {
+			t.Fatal(err)
+		}
+		if left != 0 || ledger != left {
+			t.Errorf("product %d: expected stock 0 matching the ledger, got stock %d and ledger %d", p.ID, left, ledger)
+		}
+	}
+}
+
+// TestCheckout_RespectsReservationsUnderConcurrency parks carts while other
+// checkouts sell the same product without ?lock=true, against the database in
+// TEST_DATABASE_URL. A reservation may never be left holding stock that was sold.
+func TestCheckout_RespectsReservationsUnderConcurrency(t *testing.T) {
+	db, cashier, suffix := openConcurrencyDB(t)
+	defer db.Close()
+
+	const stock, carts, buyers = 10, 20, 20
+	product := models.Product{Name: fmt.Sprintf("Reservation %d", suffix), Price: 1000, Stock: stock}
+	if err := NewProductRepository(db).Create(&product, cashier.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cartRepo := NewCartRepository(db, models.TaxConfig{})
+	cartIDs := make([]int, 0, carts)
+	for i := 0; i < carts; i++ {
+		cart := models.Cart{CreatedAt: time.Now()}
+		if err := cartRepo.Create(&cart); err != nil {
+			t.Fatal(err)
+		}
+		if err := cartRepo.AddItem(cart.ID, models.CartItemRequest{ProductID: product.ID, Quantity: 1}); err != nil {
+			t.Fatal(err)
+		}
+		cartIDs = append(cartIDs, cart.ID)
+	}
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sold, parked := 0, 0
+	record := func(err error, count *int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			*count++
+		} else if !strings.Contains(err.Error(), "insufficient stock") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	for i := 0; i < carts; i++ {
+		wg.Add(2)
+		go func(cartID int) {
+			defer wg.Done()
+			record(cartRepo.Park(cartID, time.Now().Add(time.Hour)), &parked)
+		}(cartIDs[i])
+		go func() {
+			defer wg.Done()
+			_, err := repo.CreateTransaction(models.CheckoutRequest{
+				Items:     []models.CheckoutItem{{ProductID: product.ID, Quantity: 1}},
+				Payments:  []models.PaymentRequest{{Method: models.PaymentMethodCash, AmountTendered: 1000}},
+				CashierID: cashier.ID,
+			}, false)
+			record(err, &sold)
+		}()
+	}
+	wg.Wait()
+
+	var left, reserved int
+	if err := db.QueryRow("SELECT stock FROM products WHERE id = $1", product.ID).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE product_id = $1", product.ID).Scan(&reserved); err != nil {
+		t.Fatal(err)
+	}
+	if sold+parked != stock || left != stock-sold || reserved != parked {
+		t.Errorf("expected the %d in stock to be sold or reserved once, got %d sold, %d parked, stock %d and %d reserved",
+			stock, sold, parked, left, reserved)
+	}
+}
+
+// openConcurrencyDB migrates the disposable database in TEST_DATABASE_URL and
+// opens a shift for a fresh cashier. The test is skipped without it.
+func openConcurrencyDB(t *testing.T) (*sql.DB, models.User, int64) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(20)
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	suffix := time.Now().UnixNano()
+	cashier := models.User{Username: fmt.Sprintf("concurrency-%d", suffix), PasswordHash: "-", Role: "cashier", CreatedAt: time.Now()}
+	if err := NewUserRepository(db).Create(&cashier); err != nil {
+		t.Fatal(err)
+	}
+	shift := models.Shift{CashierID: cashier.ID, RegisterID: fmt.Sprintf("TEST-%d", suffix), OpenedAt: time.Now()}
+	if err := NewShiftRepository(db).Open(&shift); err != nil {
+		t.Fatal(err)
+	}
+
+	return db, cashier, suffix
+}
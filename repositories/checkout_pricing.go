@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"fmt"
+	"kasir-api/models"
+	"math"
+)
+
+// checkoutLine is a cart line as seen by the pricing steps of CreateTransaction.
+type checkoutLine struct {
+	ProductID  int
+	CategoryID int
+	Quantity   int
+	Gross      int
+	Discount   int
+	TaxRate    float64
+	Tax        int
+	Total      int
+}
+
+func (l checkoutLine) net() int {
+	return l.Gross - l.Discount
+}
+
+// appliedDiscount is a discount picked by applyDiscounts. Line is the index of the
+// cart line it was given on, or -1 for a cart-level discount.
+type appliedDiscount struct {
+	Line     int
+	Discount models.Discount
+	Amount   int
+}
+
+// applyDiscounts gives every line the best matching product or category discount,
+// then the best cart discount on what is left. The cart discount is spread over
+// the lines pro rata so each line's net amount is what the customer really paid
+// for it (refunds rely on this). Discounts do not stack within the same level.
+func applyDiscounts(lines []checkoutLine, discounts []models.Discount, promoCode string) ([]appliedDiscount, error) {
+	applied := make([]appliedDiscount, 0)
+	promoFound, promoUsed := false, false
+
+	gross := 0
+	for _, line := range lines {
+		gross += line.Gross
+	}
+
+	for _, d := range discounts {
+		if d.PromoCode != nil {
+			promoFound = true
+		}
+	}
+	if promoCode != "" && !promoFound {
+		return nil, fmt.Errorf("promo code %q is not valid", promoCode)
+	}
+
+	// 1. Item-level discounts
+	for i := range lines {
+		line := &lines[i]
+		best, bestAmount := -1, 0
+		for j, d := range discounts {
+			if !discountMatchesLine(d, *line) || gross < d.MinPurchase {
+				continue
+			}
+			if amount := d.Amount(line.Gross, line.Quantity); amount > bestAmount {
+				best, bestAmount = j, amount
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		line.Discount = bestAmount
+		applied = append(applied, appliedDiscount{Line: i, Discount: discounts[best], Amount: bestAmount})
+		if discounts[best].PromoCode != nil {
+			promoUsed = true
+		}
+	}
+
+	// 2. Cart-level discount on the remaining amount
+	net := 0
+	for _, line := range lines {
+		net += line.net()
+	}
+
+	best, bestAmount := -1, 0
+	for j, d := range discounts {
+		if d.Scope != models.DiscountScopeCart || net < d.MinPurchase {
+			continue
+		}
+		if amount := d.Amount(net, 0); amount > bestAmount {
+			best, bestAmount = j, amount
+		}
+	}
+	if best >= 0 {
+		allocateDiscount(lines, bestAmount, net)
+		applied = append(applied, appliedDiscount{Line: -1, Discount: discounts[best], Amount: bestAmount})
+		if discounts[best].PromoCode != nil {
+			promoUsed = true
+		}
+	}
+
+	if promoCode != "" && !promoUsed {
+		return nil, fmt.Errorf("promo code %q cannot be applied to this cart", promoCode)
+	}
+
+	return applied, nil
+}
+
+func discountMatchesLine(d models.Discount, line checkoutLine) bool {
+	switch d.Scope {
+	case models.DiscountScopeProduct:
+		return d.ProductID != nil && *d.ProductID == line.ProductID
+	case models.DiscountScopeCategory:
+		return d.CategoryID != nil && *d.CategoryID == line.CategoryID
+	}
+	return false
+}
+
+// allocateDiscount spreads amount over the lines in proportion to their net value.
+// Shares are taken from what is still left to allocate, so rounding never pushes a
+// line below zero and the shares always add up to amount.
+func allocateDiscount(lines []checkoutLine, amount, net int) {
+	remainingAmount, remainingNet := amount, net
+	for i := range lines {
+		lineNet := lines[i].net()
+		if lineNet <= 0 || remainingNet <= 0 {
+			continue
+		}
+		share := remainingAmount * lineNet / remainingNet
+		if lineNet == remainingNet {
+			share = remainingAmount
+		}
+		lines[i].Discount += share
+		remainingAmount -= share
+		remainingNet -= lineNet
+	}
+}
+
+// lineTaxRate resolves the tax rate of a product: exempt products pay none, and a
+// category rate, when set, overrides the store default.
+func lineTaxRate(cfg models.TaxConfig, exempt bool, categoryRate *float64) float64 {
+	if exempt {
+		return 0
+	}
+	if categoryRate != nil {
+		return *categoryRate
+	}
+	return cfg.Rate
+}
+
+// applyTax works out the tax of every line from its net amount and returns the
+// pre-tax subtotal, total tax and service charge. With inclusive pricing the tax
+// is carved out of the net amount, otherwise it is added on top. The service
+// charge is taken from the pre-tax subtotal and is not taxed.
+func applyTax(lines []checkoutLine, cfg models.TaxConfig) (subtotal, tax, service int) {
+	for i := range lines {
+		line := &lines[i]
+		net := line.net()
+		if cfg.Inclusive {
+			line.Tax = net - roundAmount(float64(net)*100/(100+line.TaxRate))
+			line.Total = net
+			subtotal += net - line.Tax
+		} else {
+			line.Tax = roundAmount(float64(net) * line.TaxRate / 100)
+			line.Total = net + line.Tax
+			subtotal += net
+		}
+		tax += line.Tax
+	}
+
+	service = roundAmount(float64(subtotal) * cfg.ServiceChargeRate / 100)
+	return subtotal, tax, service
+}
+
+func roundAmount(v float64) int {
+	return int(math.Round(v))
+}
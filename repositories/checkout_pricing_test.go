@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"kasir-api/models"
+	"testing"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func strPtr(v string) *string {
+	return &v
+}
+
+func TestApplyDiscounts_BestItemThenCart(t *testing.T) {
+	lines := []checkoutLine{
+		{ProductID: 1, CategoryID: 1, Quantity: 2, Gross: 7000},
+		{ProductID: 2, CategoryID: 2, Quantity: 1, Gross: 3000},
+	}
+	discounts := []models.Discount{
+		{ID: 1, Name: "Makanan 10%", Type: models.DiscountTypePercentage, Value: 10, Scope: models.DiscountScopeCategory, CategoryID: intPtr(1)},
+		{ID: 2, Name: "Indomie 500", Type: models.DiscountTypeFixed, Value: 500, Scope: models.DiscountScopeProduct, ProductID: intPtr(1)},
+		{ID: 3, Name: "Belanja hemat", Type: models.DiscountTypeFixed, Value: 1000, Scope: models.DiscountScopeCart, MinPurchase: 5000},
+	}
+
+	applied, err := applyDiscounts(lines, discounts, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Indomie: 500 x 2 beats 10% of 7000, then 1000 off the 9000 cart split 6000:3000
+	if len(applied) != 2 || applied[0].Discount.ID != 2 || applied[1].Discount.ID != 3 {
+		t.Fatalf("unexpected discounts applied: %+v", applied)
+	}
+	if lines[0].Discount != 1000+666 || lines[1].Discount != 334 {
+		t.Errorf("unexpected line discounts: %d, %d", lines[0].Discount, lines[1].Discount)
+	}
+	if lines[0].net()+lines[1].net() != 8000 {
+		t.Errorf("expected net total 8000, got %d", lines[0].net()+lines[1].net())
+	}
+}
+
+func TestApplyDiscounts_PromoCode(t *testing.T) {
+	discounts := []models.Discount{
+		{ID: 4, Name: "Promo", Type: models.DiscountTypePercentage, Value: 20, Scope: models.DiscountScopeCart, PromoCode: strPtr("HEMAT20"), MinPurchase: 50000},
+	}
+
+	lines := []checkoutLine{{ProductID: 1, Quantity: 1, Gross: 10000}}
+	if _, err := applyDiscounts(lines, discounts, "HEMAT20"); err == nil {
+		t.Error("expected error when promo code minimum purchase is not met")
+	}
+
+	if _, err := applyDiscounts(lines, nil, "NOPE"); err == nil {
+		t.Error("expected error for unknown promo code")
+	}
+
+	lines = []checkoutLine{{ProductID: 1, Quantity: 5, Gross: 60000}}
+	if _, err := applyDiscounts(lines, discounts, "hemat20"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if lines[0].Discount != 12000 {
+		t.Errorf("expected discount 12000, got %d", lines[0].Discount)
+	}
+}
+
+func TestApplyTax(t *testing.T) {
+	food := 10.0
+
+	t.Run("exclusive with service charge", func(t *testing.T) {
+		lines := []checkoutLine{
+			{Gross: 10000, TaxRate: 11},
+			{Gross: 5000, Discount: 1000, TaxRate: lineTaxRate(models.TaxConfig{Rate: 11}, false, &food)},
+			{Gross: 3000, TaxRate: lineTaxRate(models.TaxConfig{Rate: 11}, true, nil)},
+		}
+		subtotal, tax, service := applyTax(lines, models.TaxConfig{Rate: 11, ServiceChargeRate: 5})
+
+		if subtotal != 17000 || tax != 1100+400 || service != 850 {
+			t.Errorf("unexpected totals: subtotal %d, tax %d, service %d", subtotal, tax, service)
+		}
+		if lines[0].Total != 11100 || lines[2].Total != 3000 {
+			t.Errorf("unexpected line totals: %d, %d", lines[0].Total, lines[2].Total)
+		}
+	})
+
+	t.Run("inclusive", func(t *testing.T) {
+		lines := []checkoutLine{{Gross: 11100, TaxRate: 11}}
+		subtotal, tax, service := applyTax(lines, models.TaxConfig{Rate: 11, Inclusive: true})
+
+		if subtotal != 10000 || tax != 1100 || service != 0 {
+			t.Errorf("unexpected totals: subtotal %d, tax %d, service %d", subtotal, tax, service)
+		}
+		if lines[0].Total != 11100 {
+			t.Errorf("expected line total to stay 11100, got %d", lines[0].Total)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"kasir-api/models"
+	"strings"
+	"time"
+)
+
+type DiscountRepository struct {
+	db *sql.DB
+}
+
+func NewDiscountRepository(db *sql.DB) *DiscountRepository {
+	return &DiscountRepository{db: db}
+}
+
+const discountColumns = "id, name, type, value, scope, product_id, category_id, promo_code, min_purchase, starts_at, ends_at, active"
+
+func scanDiscount(scanner interface{ Scan(...any) error }, d *models.Discount) error {
+	return scanner.Scan(&d.ID, &d.Name, &d.Type, &d.Value, &d.Scope, &d.ProductID, &d.CategoryID, &d.PromoCode,
+		&d.MinPurchase, &d.StartsAt, &d.EndsAt, &d.Active)
+}
+
+func (repo *DiscountRepository) GetAll() ([]models.Discount, error) {
+	rows, err := repo.db.Query("SELECT " + discountColumns + " FROM discounts ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discounts := make([]models.Discount, 0)
+	for rows.Next() {
+		var d models.Discount
+		if err := scanDiscount(rows, &d); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, d)
+	}
+
+	return discounts, rows.Err()
+}
+
+func (repo *DiscountRepository) GetByID(id int) (*models.Discount, error) {
+	var d models.Discount
+	err := scanDiscount(repo.db.QueryRow("SELECT "+discountColumns+" FROM discounts WHERE id = $1", id), &d)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("diskon tidak ditemukan")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func (repo *DiscountRepository) Create(d *models.Discount) error {
+	query := `INSERT INTO discounts (name, type, value, scope, product_id, category_id, promo_code, min_purchase, starts_at, ends_at, active)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+	return repo.db.QueryRow(query, d.Name, d.Type, d.Value, d.Scope, d.ProductID, d.CategoryID, d.PromoCode,
+		d.MinPurchase, d.StartsAt, d.EndsAt, d.Active).Scan(&d.ID)
+}
+
+func (repo *DiscountRepository) Update(d *models.Discount) error {
+	query := `UPDATE discounts SET name = $1, type = $2, value = $3, scope = $4, product_id = $5, category_id = $6,
+promo_code = $7, min_purchase = $8, starts_at = $9, ends_at = $10, active = $11 WHERE id = $12`
+	result, err := repo.db.Exec(query, d.Name, d.Type, d.Value, d.Scope, d.ProductID, d.CategoryID, d.PromoCode,
+		d.MinPurchase, d.StartsAt, d.EndsAt, d.Active, d.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.New("diskon tidak ditemukan")
+	}
+
+	return nil
+}
+
+func (repo *DiscountRepository) Delete(id int) error {
+	result, err := repo.db.Exec("DELETE FROM discounts WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.New("diskon tidak ditemukan")
+	}
+
+	return nil
+}
+
+// activeDiscounts loads the discounts that can apply at checkout time: automatic
+// ones plus the one matching promoCode, if any.
+func activeDiscounts(q queryer, at time.Time, promoCode string) ([]models.Discount, error) {
+	query := "SELECT " + discountColumns + ` FROM discounts
+WHERE active = TRUE
+AND (starts_at IS NULL OR starts_at <= $1)
+AND (ends_at IS NULL OR ends_at >= $1)
+AND (promo_code IS NULL OR promo_code = $2)
+ORDER BY id`
+
+	rows, err := q.Query(query, at, strings.ToUpper(promoCode))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discounts := make([]models.Discount, 0)
+	for rows.Next() {
+		var d models.Discount
+		if err := scanDiscount(rows, &d); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, d)
+	}
+
+	return discounts, rows.Err()
+}
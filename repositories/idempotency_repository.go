@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"kasir-api/models"
+	"time"
+)
+
+const (
+	// idempotencyKeyTTL is how long a finished response is replayed for its key.
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyClaimTimeout frees a key whose first request never finished,
+	// for instance because the server stopped halfway. A checkout cannot commit
+	// after checkoutTimeout, so by then the first request can no longer sell.
+	idempotencyClaimTimeout = 2 * checkoutTimeout
+)
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Claim marks the key of rec as in progress. It returns nil when the caller now
+// owns the key, with rec.ClaimToken set, or the record left by the earlier
+// request with the same key.
+func (repo *IdempotencyRepository) Claim(rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	rec.ClaimToken = hex.EncodeToString(token)
+
+	_, err := repo.db.Exec(`DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2
+AND (created_at < $3 OR (status_code IS NULL AND created_at < $4))`,
+		rec.UserID, rec.Key, rec.CreatedAt.Add(-idempotencyKeyTTL), rec.CreatedAt.Add(-idempotencyClaimTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := repo.db.Exec(`INSERT INTO idempotency_keys (user_id, key, request_hash, claim_token, created_at) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, key) DO NOTHING`, rec.UserID, rec.Key, rec.RequestHash, rec.ClaimToken, rec.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 1 {
+		return nil, nil
+	}
+
+	existing := models.IdempotencyRecord{UserID: rec.UserID, Key: rec.Key}
+	err = repo.db.QueryRow(`SELECT request_hash, COALESCE(status_code, 0), content_type, response_body, created_at
+FROM idempotency_keys WHERE user_id = $1 AND key = $2`, rec.UserID, rec.Key).
+		Scan(&existing.RequestHash, &existing.StatusCode, &existing.ContentType, &existing.Body, &existing.CreatedAt)
+	if err == sql.ErrNoRows {
+		// Freed between the insert and the select; report it as in progress so the client retries
+		existing.RequestHash = rec.RequestHash
+		return &existing, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &existing, nil
+}
+
+// Complete stores the response of the request that claimed the key, unless the
+// claim has since been taken over by another request.
+func (repo *IdempotencyRepository) Complete(rec *models.IdempotencyRecord) error {
+	result, err := repo.db.Exec(`UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3
+WHERE user_id = $4 AND key = $5 AND claim_token = $6 AND status_code IS NULL`,
+		rec.StatusCode, rec.ContentType, rec.Body, rec.UserID, rec.Key, rec.ClaimToken)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("claim on idempotency key %q was taken over before the response was saved", rec.Key)
+	}
+
+	return nil
+}
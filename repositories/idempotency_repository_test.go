@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"kasir-api/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestClaimIdempotencyKey_ReturnsEarlierResponse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewIdempotencyRepository(db)
+	now := time.Now()
+	rec := &models.IdempotencyRecord{UserID: 3, Key: "tablet-1-0042", RequestHash: "hash", CreatedAt: now}
+
+	mock.ExpectExec("DELETE FROM idempotency_keys").
+		WithArgs(3, "tablet-1-0042", now.Add(-idempotencyKeyTTL), now.Add(-idempotencyClaimTimeout)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO idempotency_keys .* ON CONFLICT \\(user_id, key\\) DO NOTHING").
+		WithArgs(3, "tablet-1-0042", "hash", sqlmock.AnyArg(), now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT request_hash, COALESCE\\(status_code, 0\\), content_type, response_body, created_at FROM idempotency_keys").
+		WithArgs(3, "tablet-1-0042").
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "status_code", "content_type", "response_body", "created_at"}).
+			AddRow("hash", 200, "application/json", []byte(`{"id":9}`), now))
+
+	existing, err := repo.Claim(rec)
+	if err != nil {
+		t.Fatalf("error was not expected while claiming key: %s", err)
+	}
+	if existing == nil || existing.StatusCode != 200 || string(existing.Body) != `{"id":9}` {
+		t.Errorf("expected the stored 200 response, got %+v", existing)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCompleteIdempotencyKey_RejectsTakenOverClaim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewIdempotencyRepository(db)
+	rec := &models.IdempotencyRecord{UserID: 3, Key: "tablet-1-0042", ClaimToken: "old-token", StatusCode: 200, ContentType: "application/json", Body: []byte(`{"id":9}`)}
+
+	// Another request took the key over, so its token no longer matches
+	mock.ExpectExec("UPDATE idempotency_keys SET status_code = \\$1, content_type = \\$2, response_body = \\$3 WHERE user_id = \\$4 AND key = \\$5 AND claim_token = \\$6 AND status_code IS NULL").
+		WithArgs(200, "application/json", []byte(`{"id":9}`), 3, "tablet-1-0042", "old-token").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.Complete(rec); err == nil {
+		t.Fatal("expected an error when the claim was taken over")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -3,9 +3,22 @@ package repositories
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"kasir-api/models"
+
+	"github.com/lib/pq"
 )
 
+// reservedStockJoin adds r.reserved, the quantity of each product held for
+// parked carts.
+const reservedStockJoin = `LEFT JOIN (SELECT product_id, SUM(quantity) AS reserved FROM stock_reservations WHERE expires_at > NOW() GROUP BY product_id) r
+ON r.product_id = p.id`
+
+// availableStock is what can still be sold once reservations are taken off.
+func availableStock(stock, reserved int) int {
+	return max(stock-reserved, 0)
+}
+
 type ProductRepository struct {
 	db *sql.DB
 }
@@ -14,13 +27,21 @@ func NewProductRepository(db *sql.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (repo *ProductRepository) GetAll(name string) ([]models.Product, error) {
-	query := `SELECT p.id, p.name, p.price, p.stock, p.category_id, c.name as category_name
+// GetAll lists the products, optionally filtered by name or SKU. Archived
+// products are left out unless includeArchived is set.
+func (repo *ProductRepository) GetAll(name string, includeArchived bool) ([]models.Product, error) {
+	query := `SELECT p.id, COALESCE(p.sku, ''), p.name, p.price, p.cost_price, p.stock, p.base_unit, p.category_id, c.name as category_name, p.tax_exempt,
+p.reorder_point, p.reorder_qty, p.archived_at, COALESCE(r.reserved, 0)
 FROM products p
-LEFT JOIN categories c ON p.category_id = c.id`
+LEFT JOIN categories c ON p.category_id = c.id
+` + reservedStockJoin + `
+WHERE TRUE`
 
+	if !includeArchived {
+		query += ` AND p.archived_at IS NULL`
+	}
 	if name != "" {
-		query += ` WHERE p.name ILIKE $1`
+		query += ` AND (p.name ILIKE $1 OR p.sku ILIKE $1)`
 	}
 
 	var rows *sql.Rows
@@ -40,62 +61,270 @@ LEFT JOIN categories c ON p.category_id = c.id`
 	products := make([]models.Product, 0)
 	for rows.Next() {
 		var p models.Product
-		err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoryID, &p.CategoryName)
+		err := rows.Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.CostPrice, &p.Stock, &p.BaseUnit, &p.CategoryID, &p.CategoryName, &p.TaxExempt,
+			&p.ReorderPoint, &p.ReorderQty, &p.ArchivedAt, &p.Reserved)
 		if err != nil {
 			return nil, err
 		}
+		p.Available = availableStock(p.Stock, p.Reserved)
 		products = append(products, p)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return products, nil
+	if err := repo.attachBarcodes(products); err != nil {
+		return nil, err
+	}
+	return products, repo.attachUnits(products)
 }
 
-func (repo *ProductRepository) Create(product *models.Product) error {
-	query := "INSERT INTO products (name, price, stock, category_id) VALUES ($1, $2, $3, $4) RETURNING id"
-	err := repo.db.QueryRow(query, product.Name, product.Price, product.Stock, product.CategoryID).Scan(&product.ID)
-	return err
+// Create inserts the product and records its opening stock in the stock ledger.
+func (repo *ProductRepository) Create(product *models.Product, userID int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO products (sku, name, price, cost_price, stock, base_unit, category_id, tax_exempt, reorder_point, reorder_qty) VALUES (NULLIF($1, ''), $2, $3, COALESCE($4, 0), $5, $6, $7, $8, $9, $10) RETURNING id, cost_price"
+	err = tx.QueryRow(query, product.SKU, product.Name, product.Price, product.CostPrice, product.Stock, product.BaseUnit, product.CategoryID, product.TaxExempt,
+		product.ReorderPoint, product.ReorderQty).Scan(&product.ID, &product.CostPrice)
+	if err != nil {
+		return duplicateCode(err)
+	}
+
+	if product.Barcodes == nil {
+		product.Barcodes = make([]string, 0)
+	}
+	if err := saveBarcodes(tx, product.ID, product.Barcodes); err != nil {
+		return err
+	}
+	if product.Units == nil {
+		product.Units = make([]models.ProductUnit, 0)
+	}
+	if err := saveUnits(tx, product.ID, product.Units); err != nil {
+		return err
+	}
+
+	if product.Stock != 0 {
+		err = recordStockMovement(tx, &models.StockMovement{
+			ProductID:  product.ID,
+			Type:       models.StockMovementAdjustment,
+			Quantity:   product.Stock,
+			StockAfter: product.Stock,
+			Reason:     "stok awal",
+			UserID:     userRef(userID),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLowStock returns the products at or below their reorder point, the
+// furthest below first.
+func (repo *ProductRepository) GetLowStock() ([]models.Product, error) {
+	query := `SELECT p.id, COALESCE(p.sku, ''), p.name, p.price, p.cost_price, p.stock, p.base_unit, p.category_id, c.name as category_name, p.tax_exempt,
+p.reorder_point, p.reorder_qty, p.archived_at, COALESCE(r.reserved, 0)
+FROM products p
+LEFT JOIN categories c ON p.category_id = c.id
+` + reservedStockJoin + `
+WHERE p.reorder_point IS NOT NULL AND p.stock <= p.reorder_point AND p.archived_at IS NULL
+ORDER BY p.stock - p.reorder_point, p.name`
+
+	rows, err := repo.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]models.Product, 0)
+	for rows.Next() {
+		var p models.Product
+		err := rows.Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.CostPrice, &p.Stock, &p.BaseUnit, &p.CategoryID, &p.CategoryName, &p.TaxExempt,
+			&p.ReorderPoint, &p.ReorderQty, &p.ArchivedAt, &p.Reserved)
+		if err != nil {
+			return nil, err
+		}
+		p.Available = availableStock(p.Stock, p.Reserved)
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := repo.attachBarcodes(products); err != nil {
+		return nil, err
+	}
+	return products, repo.attachUnits(products)
 }
 
 // GetByID - ambil produk by ID
 func (repo *ProductRepository) GetByID(id int) (*models.Product, error) {
-	query := `SELECT p.id, p.name, p.price, p.stock, p.category_id, c.name as category_name
+	return repo.getProduct("p.id = $1", id)
+}
+
+// GetByBarcode returns the product the scanned barcode belongs to.
+func (repo *ProductRepository) GetByBarcode(code string) (*models.Product, error) {
+	return repo.getProduct("p.id = (SELECT product_id FROM product_barcodes WHERE code = $1)", code)
+}
+
+func (repo *ProductRepository) getProduct(condition string, arg any) (*models.Product, error) {
+	query := `SELECT p.id, COALESCE(p.sku, ''), p.name, p.price, p.cost_price, p.stock, p.base_unit, p.category_id, c.name as category_name, p.tax_exempt,
+p.reorder_point, p.reorder_qty, p.archived_at, COALESCE(r.reserved, 0)
 FROM products p
 LEFT JOIN categories c ON p.category_id = c.id
-WHERE p.id = $1`
+` + reservedStockJoin + `
+WHERE ` + condition
 
 	var p models.Product
-	err := repo.db.QueryRow(query, id).Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoryID, &p.CategoryName)
+	err := repo.db.QueryRow(query, arg).Scan(&p.ID, &p.SKU, &p.Name, &p.Price, &p.CostPrice, &p.Stock, &p.BaseUnit, &p.CategoryID, &p.CategoryName, &p.TaxExempt,
+		&p.ReorderPoint, &p.ReorderQty, &p.ArchivedAt, &p.Reserved)
 	if err == sql.ErrNoRows {
 		return nil, errors.New("produk tidak ditemukan")
 	}
 	if err != nil {
 		return nil, err
 	}
+	p.Available = availableStock(p.Stock, p.Reserved)
+
+	barcodes, err := productBarcodes(repo.db, []int{p.ID})
+	if err != nil {
+		return nil, err
+	}
+	p.Barcodes = barcodes[p.ID]
+
+	units, err := productUnits(repo.db, []int{p.ID})
+	if err != nil {
+		return nil, err
+	}
+	p.Units = units[p.ID]
 
 	return &p, nil
 }
 
+// Update saves the product details. Stock is left alone: it only changes through
+// checkouts, refunds and StockRepository.Adjust, so every change is in the ledger.
+// Barcodes are replaced when given and kept when product.Barcodes is nil; the same
+// goes for units and cost_price.
 func (repo *ProductRepository) Update(product *models.Product) error {
-	query := "UPDATE products SET name = $1, price = $2, stock = $3, category_id = $4 WHERE id = $5"
-	result, err := repo.db.Exec(query, product.Name, product.Price, product.Stock, product.CategoryID, product.ID)
+	tx, err := repo.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rows, err := result.RowsAffected()
+	if product.BaseUnit != "" {
+		if err := checkBaseUnitChange(tx, product); err != nil {
+			return err
+		}
+	}
+
+	query := "UPDATE products SET sku = NULLIF($1, ''), name = $2, price = $3, cost_price = COALESCE($4, cost_price), category_id = $5, tax_exempt = $6, reorder_point = $7, reorder_qty = $8, base_unit = COALESCE(NULLIF($10, ''), base_unit) WHERE id = $9 RETURNING stock, cost_price, base_unit"
+	err = tx.QueryRow(query, product.SKU, product.Name, product.Price, product.CostPrice, product.CategoryID, product.TaxExempt,
+		product.ReorderPoint, product.ReorderQty, product.ID, product.BaseUnit).Scan(&product.Stock, &product.CostPrice, &product.BaseUnit)
+	if err == sql.ErrNoRows {
+		return errors.New("produk tidak ditemukan")
+	}
 	if err != nil {
-		return err
+		return duplicateCode(err)
 	}
 
-	if rows == 0 {
+	if product.Barcodes != nil {
+		if _, err := tx.Exec("DELETE FROM product_barcodes WHERE product_id = $1", product.ID); err != nil {
+			return err
+		}
+		if err := saveBarcodes(tx, product.ID, product.Barcodes); err != nil {
+			return err
+		}
+	} else {
+		barcodes, err := productBarcodes(tx, []int{product.ID})
+		if err != nil {
+			return err
+		}
+		product.Barcodes = barcodes[product.ID]
+	}
+
+	if product.Units != nil {
+		if err := checkRemovedUnits(tx, product); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM product_units WHERE product_id = $1", product.ID); err != nil {
+			return err
+		}
+		if err := saveUnits(tx, product.ID, product.Units); err != nil {
+			return err
+		}
+	} else {
+		units, err := productUnits(tx, []int{product.ID})
+		if err != nil {
+			return err
+		}
+		product.Units = units[product.ID]
+	}
+
+	return tx.Commit()
+}
+
+// checkBaseUnitChange locks the product and refuses a new base unit while
+// anything is counted in the old one: stock, its stock ledger, or pack units
+// that are kept rather than replaced by this update.
+func checkBaseUnitChange(tx *sql.Tx, product *models.Product) error {
+	var baseUnit string
+	var stock int
+	var hasMovements, hasUnits bool
+	err := tx.QueryRow(`SELECT p.base_unit, p.stock, EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id),
+EXISTS (SELECT 1 FROM product_units u WHERE u.product_id = p.id)
+FROM products p
+WHERE p.id = $1
+FOR UPDATE`, product.ID).Scan(&baseUnit, &stock, &hasMovements, &hasUnits)
+	if err == sql.ErrNoRows {
 		return errors.New("produk tidak ditemukan")
 	}
+	if err != nil {
+		return err
+	}
 
+	if product.BaseUnit == baseUnit {
+		return nil
+	}
+	if stock != 0 || hasMovements || (hasUnits && product.Units == nil) {
+		return fmt.Errorf("base_unit cannot change from %s to %s once the product has stock, stock movements or units", baseUnit, product.BaseUnit)
+	}
 	return nil
 }
 
+// checkRemovedUnits refuses to drop or rename a pack unit that open or parked
+// carts still hold lines in, since those lines could no longer be priced or reserved.
+func checkRemovedUnits(tx *sql.Tx, product *models.Product) error {
+	keep := make([]string, 0, len(product.Units))
+	for _, u := range product.Units {
+		keep = append(keep, u.Unit)
+	}
+
+	var unit string
+	err := tx.QueryRow(`SELECT ci.unit
+FROM cart_items ci
+JOIN carts c ON ci.cart_id = c.id
+WHERE ci.product_id = $1 AND ci.unit <> '' AND c.status IN ($2, $3) AND ci.unit <> ALL($4)
+ORDER BY ci.unit
+LIMIT 1`, product.ID, models.CartStatusOpen, models.CartStatusParked, pq.Array(keep)).Scan(&unit)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("unit %s is still used in open or parked carts and cannot be removed", unit)
+}
+
+// Delete archives the product. It keeps its row so transactions, stock movements
+// and purchase orders that refer to it stay intact.
 func (repo *ProductRepository) Delete(id int) error {
-	query := "DELETE FROM products WHERE id = $1"
+	query := "UPDATE products SET archived_at = COALESCE(archived_at, NOW()) WHERE id = $1"
 	result, err := repo.db.Exec(query, id)
 	if err != nil {
 		return err
@@ -111,3 +340,168 @@ func (repo *ProductRepository) Delete(id int) error {
 
 	return err
 }
+
+// Restore brings an archived product back.
+func (repo *ProductRepository) Restore(id int) error {
+	result, err := repo.db.Exec("UPDATE products SET archived_at = NULL WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.New("produk tidak ditemukan")
+	}
+
+	return nil
+}
+
+// missingProduct returns the first of productIDs that does not exist, or 0 when
+// they all do.
+func missingProduct(q queryer, productIDs []int) (int, error) {
+	rows, err := q.Query("SELECT id FROM products WHERE id = ANY($1)", pq.Array(productIDs))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	found := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range productIDs {
+		if !found[id] {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
+// attachBarcodes loads the barcodes of all products in one query.
+func (repo *ProductRepository) attachBarcodes(products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	barcodes, err := productBarcodes(repo.db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		products[i].Barcodes = barcodes[products[i].ID]
+	}
+	return nil
+}
+
+// productBarcodes returns the barcodes of the given products keyed by product
+// ID, with an empty list for products that have none.
+func productBarcodes(q queryer, productIDs []int) (map[int][]string, error) {
+	rows, err := q.Query("SELECT product_id, code FROM product_barcodes WHERE product_id = ANY($1) ORDER BY id", pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	barcodes := make(map[int][]string, len(productIDs))
+	for _, id := range productIDs {
+		barcodes[id] = make([]string, 0)
+	}
+	for rows.Next() {
+		var id int
+		var code string
+		if err := rows.Scan(&id, &code); err != nil {
+			return nil, err
+		}
+		barcodes[id] = append(barcodes[id], code)
+	}
+
+	return barcodes, rows.Err()
+}
+
+// attachUnits loads the units of all products in one query.
+func (repo *ProductRepository) attachUnits(products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	units, err := productUnits(repo.db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		products[i].Units = units[products[i].ID]
+	}
+	return nil
+}
+
+// productUnits returns the units of the given products keyed by product ID,
+// smallest first, with an empty list for products sold in their base unit only.
+func productUnits(q queryer, productIDs []int) (map[int][]models.ProductUnit, error) {
+	rows, err := q.Query("SELECT product_id, unit, factor, price FROM product_units WHERE product_id = ANY($1) ORDER BY factor, id", pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := make(map[int][]models.ProductUnit, len(productIDs))
+	for _, id := range productIDs {
+		units[id] = make([]models.ProductUnit, 0)
+	}
+	for rows.Next() {
+		var id int
+		var u models.ProductUnit
+		if err := rows.Scan(&id, &u.Unit, &u.Factor, &u.Price); err != nil {
+			return nil, err
+		}
+		units[id] = append(units[id], u)
+	}
+
+	return units, rows.Err()
+}
+
+func saveUnits(tx *sql.Tx, productID int, units []models.ProductUnit) error {
+	for _, u := range units {
+		_, err := tx.Exec("INSERT INTO product_units (product_id, unit, factor, price) VALUES ($1, $2, $3, $4)", productID, u.Unit, u.Factor, u.Price)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveBarcodes(tx *sql.Tx, productID int, codes []string) error {
+	for _, code := range codes {
+		if _, err := tx.Exec("INSERT INTO product_barcodes (product_id, code) VALUES ($1, $2)", productID, code); err != nil {
+			return duplicateCode(err)
+		}
+	}
+	return nil
+}
+
+// duplicateCode turns a unique violation on a SKU or barcode into a readable error.
+func duplicateCode(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return errors.New("sku atau barcode sudah dipakai produk lain")
+	}
+	return err
+}
@@ -0,0 +1,251 @@
+package repositories
+
+import (
+	"database/sql"
+	"kasir-api/models"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUpdateProduct_KeepsStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewProductRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE products SET sku = NULLIF($1, ''), name = $2, price = $3, cost_price = COALESCE($4, cost_price), category_id = $5, tax_exempt = $6, reorder_point = $7, reorder_qty = $8, base_unit = COALESCE(NULLIF($10, ''), base_unit) WHERE id = $9 RETURNING stock, cost_price, base_unit")).
+		WithArgs("KCP-01", "Kecap", 12000, 9000, 1, false, nil, 0, 4, "").
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "cost_price", "base_unit"}).AddRow(10, 9000, "btl"))
+	mock.ExpectExec("DELETE FROM product_barcodes WHERE product_id = \\$1").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO product_barcodes").
+		WithArgs(4, "8992388101016").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT ci.unit FROM cart_items ci").
+		WithArgs(4, models.CartStatusOpen, models.CartStatusParked, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"unit"}))
+	mock.ExpectExec("DELETE FROM product_units WHERE product_id = \\$1").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO product_units").
+		WithArgs(4, "dus", 24, 280000).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	product := models.Product{ID: 4, SKU: "KCP-01", Barcodes: []string{"8992388101016"}, Name: "Kecap", Price: 12000, CostPrice: intPtr(9000), Stock: 7, CategoryID: 1,
+		Units: []models.ProductUnit{{Unit: "dus", Factor: 24, Price: 280000}}}
+	if err := repo.Update(&product); err != nil {
+		t.Fatalf("error was not expected while updating product: %s", err)
+	}
+	if product.Stock != 10 || product.BaseUnit != "btl" {
+		t.Errorf("expected stock to stay at 10 btl, got %d %s", product.Stock, product.BaseUnit)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateProduct_KeepsCostPriceWhenOmitted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewProductRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("cost_price = COALESCE($4, cost_price)")).
+		WithArgs("", "Kecap", 12500, nil, 1, false, nil, 0, 4, "").
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "cost_price", "base_unit"}).AddRow(10, 9000, "btl"))
+	mock.ExpectQuery("SELECT product_id, code FROM product_barcodes").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "code"}))
+	mock.ExpectQuery("SELECT product_id, unit, factor, price FROM product_units").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "unit", "factor", "price"}))
+	mock.ExpectCommit()
+
+	product := models.Product{ID: 4, Name: "Kecap", Price: 12500, CategoryID: 1}
+	if err := repo.Update(&product); err != nil {
+		t.Fatalf("error was not expected while updating product: %s", err)
+	}
+	if product.CostPrice == nil || *product.CostPrice != 9000 {
+		t.Errorf("expected stored cost_price 9000 to be kept, got %v", product.CostPrice)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateProduct_RejectsRemovingUnitInCart(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewProductRepository(db)
+
+	// dus is renamed to karton while a parked cart still holds 2 dus
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE products SET sku")).
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "cost_price", "base_unit"}).AddRow(10, 9000, "btl"))
+	mock.ExpectQuery("SELECT product_id, code FROM product_barcodes").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "code"}))
+	mock.ExpectQuery("SELECT ci.unit FROM cart_items ci").
+		WithArgs(4, models.CartStatusOpen, models.CartStatusParked, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"unit"}).AddRow("dus"))
+	mock.ExpectRollback()
+
+	product := models.Product{ID: 4, Name: "Kecap", Price: 12000, CategoryID: 1,
+		Units: []models.ProductUnit{{Unit: "karton", Factor: 24, Price: 280000}}}
+	err = repo.Update(&product)
+	if err == nil || !strings.Contains(err.Error(), "unit dus is still used") {
+		t.Fatalf("expected removing a unit used in carts to fail, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateProduct_RejectsBaseUnitChangeWithStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewProductRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT p.base_unit, p.stock, EXISTS .* FROM products p WHERE p.id = \\$1 FOR UPDATE").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"base_unit", "stock", "has_movements", "has_units"}).AddRow("btl", 10, true, false))
+	mock.ExpectRollback()
+
+	product := models.Product{ID: 4, Name: "Kecap", Price: 12000, BaseUnit: "ml"}
+	err = repo.Update(&product)
+	if err == nil || err.Error() != "base_unit cannot change from btl to ml once the product has stock, stock movements or units" {
+		t.Fatalf("expected the base unit change to be rejected, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDeleteProduct_Archives(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewProductRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE products SET archived_at = COALESCE(archived_at, NOW()) WHERE id = $1")).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.Delete(4); err != nil {
+		t.Fatalf("error was not expected while deleting product: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetAllProducts_HidesArchived(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewProductRepository(db)
+	columns := []string{"id", "sku", "name", "price", "cost_price", "stock", "base_unit", "category_id", "category_name", "tax_exempt", "reorder_point", "reorder_qty", "archived_at", "reserved"}
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE TRUE AND p.archived_at IS NULL AND (p.name ILIKE $1 OR p.sku ILIKE $1)")).
+		WithArgs("%kecap%").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(4, "KCP-01", "Kecap", 12000, 9000, 10, "btl", 1, "Makanan", false, nil, 0, nil, 0))
+
+	mock.ExpectQuery("FROM product_barcodes WHERE product_id = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "code"}).AddRow(4, "8992388101016"))
+	mock.ExpectQuery("FROM product_units WHERE product_id = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "unit", "factor", "price"}))
+
+	products, err := repo.GetAll("kecap", false)
+	if err != nil {
+		t.Fatalf("error was not expected while listing products: %s", err)
+	}
+	if len(products) != 1 || len(products[0].Barcodes) != 1 || products[0].Barcodes[0] != "8992388101016" {
+		t.Errorf("expected Kecap with its barcode, got %+v", products)
+	}
+
+	mock.ExpectQuery("WHERE TRUE$").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	if _, err := repo.GetAll("", true); err != nil {
+		t.Fatalf("error was not expected while listing archived products: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetProductByBarcode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewProductRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE p.id = (SELECT product_id FROM product_barcodes WHERE code = $1)")).
+		WithArgs("8992388101016").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "sku", "name", "price", "cost_price", "stock", "base_unit", "category_id", "category_name", "tax_exempt", "reorder_point", "reorder_qty", "archived_at", "reserved"}).
+			AddRow(4, "", "Kecap", 12000, 9000, 10, "btl", 1, "Makanan", false, nil, 0, nil, 4))
+	mock.ExpectQuery("FROM product_barcodes WHERE product_id = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "code"}).AddRow(4, "8992388101016").AddRow(4, "036000291452"))
+	mock.ExpectQuery("FROM product_units WHERE product_id = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "unit", "factor", "price"}).AddRow(4, "dus", 24, 280000))
+
+	product, err := repo.GetByBarcode("8992388101016")
+	if err != nil {
+		t.Fatalf("error was not expected while looking up barcode: %s", err)
+	}
+	if product.ID != 4 || len(product.Barcodes) != 2 || len(product.Units) != 1 || product.Units[0].Factor != 24 {
+		t.Errorf("expected product 4 with two barcodes and a dus of 24, got %+v", product)
+	}
+	if product.Stock != 10 || product.Reserved != 4 || product.Available != 6 {
+		t.Errorf("expected 10 on hand with 4 reserved and 6 available, got %d, %d, %d", product.Stock, product.Reserved, product.Available)
+	}
+
+	mock.ExpectQuery("FROM product_barcodes WHERE code").
+		WithArgs("4006381333931").
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.GetByBarcode("4006381333931"); err == nil || err.Error() != "produk tidak ditemukan" {
+		t.Errorf("expected not found for an unknown barcode, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
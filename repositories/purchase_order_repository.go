@@ -0,0 +1,444 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"kasir-api/models"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type PurchaseOrderRepository struct {
+	db *sql.DB
+}
+
+func NewPurchaseOrderRepository(db *sql.DB) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{db: db}
+}
+
+const purchaseOrderSelect = `SELECT po.id, po.supplier_id, s.name, po.status, COALESCE(po.notes, ''), po.created_by,
+COALESCE((SELECT SUM(i.quantity * i.unit_cost) FROM purchase_order_items i WHERE i.purchase_order_id = po.id), 0),
+po.created_at, po.ordered_at, po.closed_at
+FROM purchase_orders po
+JOIN suppliers s ON po.supplier_id = s.id`
+
+func scanPurchaseOrder(scanner interface{ Scan(...any) error }, po *models.PurchaseOrder) error {
+	return scanner.Scan(&po.ID, &po.SupplierID, &po.SupplierName, &po.Status, &po.Notes, &po.CreatedBy,
+		&po.TotalCost, &po.CreatedAt, &po.OrderedAt, &po.ClosedAt)
+}
+
+func (repo *PurchaseOrderRepository) GetAll(filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("po.status = $%d", len(args)))
+	}
+	if filter.SupplierID > 0 {
+		args = append(args, filter.SupplierID)
+		conditions = append(conditions, fmt.Sprintf("po.supplier_id = $%d", len(args)))
+	}
+
+	query := purchaseOrderSelect
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := repo.db.Query(query+" ORDER BY po.created_at DESC, po.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]models.PurchaseOrder, 0)
+	for rows.Next() {
+		var po models.PurchaseOrder
+		if err := scanPurchaseOrder(rows, &po); err != nil {
+			return nil, err
+		}
+		orders = append(orders, po)
+	}
+
+	return orders, rows.Err()
+}
+
+// GetByID returns the purchase order with its lines and goods receipts.
+func (repo *PurchaseOrderRepository) GetByID(id int) (*models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	err := scanPurchaseOrder(repo.db.QueryRow(purchaseOrderSelect+" WHERE po.id = $1", id), &po)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("purchase order id %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	po.Items, err = purchaseOrderItems(repo.db, id)
+	if err != nil {
+		return nil, err
+	}
+	po.Receipts, err = repo.getReceipts(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &po, nil
+}
+
+// Create saves a new draft purchase order with its lines.
+func (repo *PurchaseOrderRepository) Create(req models.PurchaseOrderRequest) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := checkPurchaseOrderRefs(tx, req); err != nil {
+		return 0, err
+	}
+
+	var createdBy *int
+	if req.CreatedBy > 0 {
+		createdBy = &req.CreatedBy
+	}
+	var id int
+	err = tx.QueryRow("INSERT INTO purchase_orders (supplier_id, status, notes, created_by, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		req.SupplierID, models.PurchaseOrderStatusDraft, req.Notes, createdBy, models.GetCurrentTime()).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertPurchaseOrderItems(tx, id, req.Items); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// UpdateDraft replaces the supplier, notes and lines of a draft purchase order.
+func (repo *PurchaseOrderRepository) UpdateDraft(id int, req models.PurchaseOrderRequest) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPurchaseOrder(tx, id)
+	if err != nil {
+		return err
+	}
+	if status != models.PurchaseOrderStatusDraft {
+		return fmt.Errorf("purchase order id %d is %s, only drafts can be edited", id, status)
+	}
+	if err := checkPurchaseOrderRefs(tx, req); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE purchase_orders SET supplier_id = $1, notes = $2 WHERE id = $3", req.SupplierID, req.Notes, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM purchase_order_items WHERE purchase_order_id = $1", id); err != nil {
+		return err
+	}
+	if err := insertPurchaseOrderItems(tx, id, req.Items); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Order sends a draft to the supplier; goods can be received from then on.
+func (repo *PurchaseOrderRepository) Order(id int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPurchaseOrder(tx, id)
+	if err != nil {
+		return err
+	}
+	if status != models.PurchaseOrderStatusDraft {
+		return fmt.Errorf("purchase order id %d is already %s", id, status)
+	}
+
+	_, err = tx.Exec("UPDATE purchase_orders SET status = $1, ordered_at = $2 WHERE id = $3",
+		models.PurchaseOrderStatusOrdered, models.GetCurrentTime(), id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Cancel cancels a draft or ordered purchase order. Orders with received goods
+// cannot be cancelled.
+func (repo *PurchaseOrderRepository) Cancel(id int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPurchaseOrder(tx, id)
+	if err != nil {
+		return err
+	}
+	if status != models.PurchaseOrderStatusDraft && status != models.PurchaseOrderStatusOrdered {
+		return fmt.Errorf("purchase order id %d is already %s", id, status)
+	}
+
+	_, err = tx.Exec("UPDATE purchase_orders SET status = $1, closed_at = $2 WHERE id = $3",
+		models.PurchaseOrderStatusCancelled, models.GetCurrentTime(), id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Receive books a delivery against an ordered purchase order: it adds the goods
+// to stock as restock movements, records the unit cost paid and moves the order
+// to partially_received or received. Each product's cost price becomes the
+// weighted average of its stock and the received goods. Products are updated in id order.
+func (repo *PurchaseOrderRepository) Receive(id int, req models.ReceiveGoodsRequest) (*models.GoodsReceipt, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPurchaseOrder(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status != models.PurchaseOrderStatusOrdered && status != models.PurchaseOrderStatusPartiallyReceived {
+		return nil, fmt.Errorf("purchase order id %d is %s, goods can only be received on ordered purchase orders", id, status)
+	}
+
+	lines, err := purchaseOrderItems(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	byProduct := make(map[int]*models.PurchaseOrderItem, len(lines))
+	for i := range lines {
+		byProduct[lines[i].ProductID] = &lines[i]
+	}
+
+	items := append([]models.ReceiveItemRequest(nil), req.Items...)
+	sort.Slice(items, func(i, j int) bool { return items[i].ProductID < items[j].ProductID })
+
+	receipt := models.GoodsReceipt{
+		PurchaseOrderID: id,
+		Notes:           req.Notes,
+		ReceivedAt:      models.GetCurrentTime(),
+		Items:           make([]models.GoodsReceiptItem, 0, len(items)),
+	}
+	if req.ReceivedBy > 0 {
+		receipt.ReceivedBy = &req.ReceivedBy
+	}
+	err = tx.QueryRow("INSERT INTO goods_receipts (purchase_order_id, notes, received_by, received_at) VALUES ($1, $2, $3, $4) RETURNING id",
+		id, receipt.Notes, receipt.ReceivedBy, receipt.ReceivedAt).Scan(&receipt.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		line, ok := byProduct[item.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("product id %d is not on purchase order %d", item.ProductID, id)
+		}
+		if outstanding := line.Quantity - line.ReceivedQuantity; item.Quantity > outstanding {
+			return nil, fmt.Errorf("cannot receive %d of product id %d, only %d outstanding", item.Quantity, item.ProductID, outstanding)
+		}
+		unitCost := line.UnitCost
+		if item.UnitCost != nil {
+			unitCost = *item.UnitCost
+		}
+
+		if _, err := tx.Exec("UPDATE purchase_order_items SET received_quantity = received_quantity + $1 WHERE id = $2", item.Quantity, line.ID); err != nil {
+			return nil, err
+		}
+		line.ReceivedQuantity += item.Quantity
+
+		// Blend the paid cost into the weighted-average cost of what is already in stock
+		var newStock int
+		err := tx.QueryRow(`UPDATE products
+SET cost_price = ROUND((GREATEST(stock, 0) * cost_price + $1 * $3)::numeric / (GREATEST(stock, 0) + $1)), stock = stock + $1
+WHERE id = $2 RETURNING stock`, item.Quantity, item.ProductID, unitCost).Scan(&newStock)
+		if err != nil {
+			return nil, err
+		}
+		err = recordStockMovement(tx, &models.StockMovement{
+			ProductID:   item.ProductID,
+			Type:        models.StockMovementRestock,
+			Quantity:    item.Quantity,
+			StockAfter:  newStock,
+			Reason:      fmt.Sprintf("purchase order #%d", id),
+			ReferenceID: &receipt.ID,
+			UserID:      receipt.ReceivedBy,
+			CreatedAt:   receipt.ReceivedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ri := models.GoodsReceiptItem{
+			PurchaseOrderItemID: line.ID,
+			ProductID:           item.ProductID,
+			Quantity:            item.Quantity,
+			UnitCost:            unitCost,
+		}
+		err = tx.QueryRow("INSERT INTO goods_receipt_items (goods_receipt_id, purchase_order_item_id, product_id, quantity, unit_cost) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			receipt.ID, ri.PurchaseOrderItemID, ri.ProductID, ri.Quantity, ri.UnitCost).Scan(&ri.ID)
+		if err != nil {
+			return nil, err
+		}
+		receipt.Items = append(receipt.Items, ri)
+	}
+
+	newStatus := models.PurchaseOrderStatusReceived
+	for _, line := range lines {
+		if line.ReceivedQuantity < line.Quantity {
+			newStatus = models.PurchaseOrderStatusPartiallyReceived
+			break
+		}
+	}
+	if newStatus == models.PurchaseOrderStatusReceived {
+		_, err = tx.Exec("UPDATE purchase_orders SET status = $1, closed_at = $2 WHERE id = $3", newStatus, receipt.ReceivedAt, id)
+	} else {
+		_, err = tx.Exec("UPDATE purchase_orders SET status = $1 WHERE id = $2", newStatus, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &receipt, nil
+}
+
+func (repo *PurchaseOrderRepository) getReceipts(purchaseOrderID int) ([]models.GoodsReceipt, error) {
+	rows, err := repo.db.Query("SELECT id, purchase_order_id, COALESCE(notes, ''), received_by, received_at FROM goods_receipts WHERE purchase_order_id = $1 ORDER BY id",
+		purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := make([]models.GoodsReceipt, 0)
+	receiptIDs := make([]int, 0)
+	for rows.Next() {
+		var gr models.GoodsReceipt
+		if err := rows.Scan(&gr.ID, &gr.PurchaseOrderID, &gr.Notes, &gr.ReceivedBy, &gr.ReceivedAt); err != nil {
+			return nil, err
+		}
+		gr.Items = make([]models.GoodsReceiptItem, 0)
+		receipts = append(receipts, gr)
+		receiptIDs = append(receiptIDs, gr.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(receipts) == 0 {
+		return receipts, nil
+	}
+
+	itemRows, err := repo.db.Query(`SELECT id, goods_receipt_id, purchase_order_item_id, product_id, quantity, unit_cost
+FROM goods_receipt_items WHERE goods_receipt_id = ANY($1) ORDER BY id`, pq.Array(receiptIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	index := make(map[int]int, len(receipts))
+	for i, gr := range receipts {
+		index[gr.ID] = i
+	}
+	for itemRows.Next() {
+		var ri models.GoodsReceiptItem
+		var receiptID int
+		if err := itemRows.Scan(&ri.ID, &receiptID, &ri.PurchaseOrderItemID, &ri.ProductID, &ri.Quantity, &ri.UnitCost); err != nil {
+			return nil, err
+		}
+		receipts[index[receiptID]].Items = append(receipts[index[receiptID]].Items, ri)
+	}
+
+	return receipts, itemRows.Err()
+}
+
+func purchaseOrderItems(q queryer, purchaseOrderID int) ([]models.PurchaseOrderItem, error) {
+	rows, err := q.Query(`SELECT i.id, i.purchase_order_id, i.product_id, p.name, i.quantity, i.unit_cost, i.received_quantity
+FROM purchase_order_items i
+JOIN products p ON i.product_id = p.id
+WHERE i.purchase_order_id = $1
+ORDER BY i.product_id`, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]models.PurchaseOrderItem, 0)
+	for rows.Next() {
+		var item models.PurchaseOrderItem
+		err := rows.Scan(&item.ID, &item.PurchaseOrderID, &item.ProductID, &item.ProductName, &item.Quantity, &item.UnitCost, &item.ReceivedQuantity)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func insertPurchaseOrderItems(tx *sql.Tx, purchaseOrderID int, items []models.PurchaseOrderItemRequest) error {
+	for _, item := range items {
+		_, err := tx.Exec("INSERT INTO purchase_order_items (purchase_order_id, product_id, quantity, unit_cost) VALUES ($1, $2, $3, $4)",
+			purchaseOrderID, item.ProductID, item.Quantity, item.UnitCost)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPurchaseOrderRefs makes sure the supplier and every product exist.
+func checkPurchaseOrderRefs(tx *sql.Tx, req models.PurchaseOrderRequest) error {
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM suppliers WHERE id = $1)", req.SupplierID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("supplier id %d not found", req.SupplierID)
+	}
+
+	productIDs := make([]int, 0, len(req.Items))
+	for _, item := range req.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	missing, err := missingProduct(tx, productIDs)
+	if err != nil {
+		return err
+	}
+	if missing != 0 {
+		return fmt.Errorf("product id %d not found", missing)
+	}
+
+	return nil
+}
+
+// lockPurchaseOrder locks the purchase order row and returns its status.
+func lockPurchaseOrder(tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRow("SELECT status FROM purchase_orders WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("purchase order id %d not found", id)
+	}
+
+	return status, err
+}
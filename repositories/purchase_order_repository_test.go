@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"kasir-api/models"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var purchaseOrderItemColumns = []string{"id", "purchase_order_id", "product_id", "name", "quantity", "unit_cost", "received_quantity"}
+
+func TestReceiveGoods_PartialDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPurchaseOrderRepository(db)
+	unitCost := 2750
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM purchase_orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.PurchaseOrderStatusOrdered))
+	mock.ExpectQuery("FROM purchase_order_items i JOIN products p").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderItemColumns).
+			AddRow(11, 7, 1, "Indomie", 48, 2800, 0).
+			AddRow(12, 7, 2, "Vit 1000ml", 24, 2000, 0))
+	mock.ExpectQuery("INSERT INTO goods_receipts").
+		WithArgs(7, "kiriman pertama", 1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	// Product 1: 24 of 48 at a lower cost than ordered
+	mock.ExpectExec("UPDATE purchase_order_items SET received_quantity").
+		WithArgs(24, 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE products SET cost_price = ROUND\\(\\(GREATEST\\(stock, 0\\) \\* cost_price \\+ \\$1 \\* \\$3\\)::numeric / \\(GREATEST\\(stock, 0\\) \\+ \\$1\\)\\), stock = stock \\+ \\$1 WHERE id = \\$2 RETURNING stock").
+		WithArgs(24, 1, 2750).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(34))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, models.StockMovementRestock, 24, 34, "purchase order #7", 3, 1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(20))
+	mock.ExpectQuery("INSERT INTO goods_receipt_items").
+		WithArgs(3, 11, 1, 24, 2750).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	// Product 2: all 24 at the ordered cost
+	mock.ExpectExec("UPDATE purchase_order_items SET received_quantity").
+		WithArgs(24, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE products SET cost_price").
+		WithArgs(24, 2, 2000).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(64))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(2, models.StockMovementRestock, 24, 64, "purchase order #7", 3, 1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+	mock.ExpectQuery("INSERT INTO goods_receipt_items").
+		WithArgs(3, 12, 2, 24, 2000).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	mock.ExpectExec("UPDATE purchase_orders SET status = \\$1 WHERE id = \\$2").
+		WithArgs(models.PurchaseOrderStatusPartiallyReceived, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	receipt, err := repo.Receive(7, models.ReceiveGoodsRequest{
+		Items: []models.ReceiveItemRequest{
+			{ProductID: 2, Quantity: 24},
+			{ProductID: 1, Quantity: 24, UnitCost: &unitCost},
+		},
+		Notes:      "kiriman pertama",
+		ReceivedBy: 1,
+	})
+	if err != nil {
+		t.Fatalf("error was not expected while receiving goods: %s", err)
+	}
+
+	if len(receipt.Items) != 2 || receipt.Items[0].UnitCost != 2750 || receipt.Items[1].UnitCost != 2000 {
+		t.Errorf("expected two receipt lines costing 2750 and 2000, got %+v", receipt.Items)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReceiveGoods_RejectsOverDelivery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPurchaseOrderRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM purchase_orders").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.PurchaseOrderStatusPartiallyReceived))
+	mock.ExpectQuery("FROM purchase_order_items i JOIN products p").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderItemColumns).AddRow(11, 7, 1, "Indomie", 48, 2800, 40))
+	mock.ExpectQuery("INSERT INTO goods_receipts").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectRollback()
+
+	_, err = repo.Receive(7, models.ReceiveGoodsRequest{Items: []models.ReceiveItemRequest{{ProductID: 1, Quantity: 10}}})
+	if err == nil || !strings.Contains(err.Error(), "only 8 outstanding") {
+		t.Fatalf("expected outstanding quantity error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
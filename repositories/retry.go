@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	conflictRetries      = 4
+	conflictRetryBackoff = 20 * time.Millisecond
+)
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// retryOnConflict runs fn again when Postgres aborted it on a deadlock or a
+// serialization failure, up to conflictRetries more times. The wait doubles
+// after each attempt and is jittered so the competing transactions do not
+// collide again in lockstep. fn must roll back its own work before returning.
+func retryOnConflict(fn func() error) error {
+	backoff := conflictRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isConflict(err) || attempt == conflictRetries {
+			return err
+		}
+		sleep(backoff/2 + rand.N(backoff))
+		backoff *= 2
+	}
+}
+
+// isConflict reports whether err is a deadlock (40P01) or serialization
+// failure (40001), after which the whole transaction can safely be retried.
+func isConflict(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40P01" || pqErr.Code == "40001"
+}
+
+// isTimeout reports whether err comes from a context deadline or a statement
+// cancelled by statement_timeout (57014).
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014"
+}
@@ -0,0 +1,259 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+)
+
+type ShiftRepository struct {
+	db *sql.DB
+}
+
+func NewShiftRepository(db *sql.DB) *ShiftRepository {
+	return &ShiftRepository{db: db}
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+const shiftSelect = `SELECT s.id, s.cashier_id, COALESCE(u.username, ''), s.register_id, s.status, s.opening_float,
+s.expected_cash, s.counted_cash, s.difference, COALESCE(s.notes, ''), s.opened_at, s.closed_at
+FROM shifts s
+LEFT JOIN users u ON s.cashier_id = u.id`
+
+func scanShift(scanner interface{ Scan(...any) error }, s *models.Shift) error {
+	return scanner.Scan(&s.ID, &s.CashierID, &s.CashierName, &s.RegisterID, &s.Status, &s.OpeningFloat,
+		&s.ExpectedCash, &s.CountedCash, &s.Difference, &s.Notes, &s.OpenedAt, &s.ClosedAt)
+}
+
+func (repo *ShiftRepository) GetAll(status string) ([]models.Shift, error) {
+	query := shiftSelect
+	args := make([]any, 0)
+	if status != "" {
+		query += " WHERE s.status = $1"
+		args = append(args, status)
+	}
+
+	rows, err := repo.db.Query(query+" ORDER BY s.opened_at DESC, s.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shifts := make([]models.Shift, 0)
+	for rows.Next() {
+		var s models.Shift
+		if err := scanShift(rows, &s); err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, s)
+	}
+
+	return shifts, rows.Err()
+}
+
+func (repo *ShiftRepository) GetByID(id int) (*models.Shift, error) {
+	var s models.Shift
+	err := scanShift(repo.db.QueryRow(shiftSelect+" WHERE s.id = $1", id), &s)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("shift id %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetOpenByCashier returns the open shift of the cashier.
+func (repo *ShiftRepository) GetOpenByCashier(cashierID int) (*models.Shift, error) {
+	var s models.Shift
+	err := scanShift(repo.db.QueryRow(shiftSelect+" WHERE s.cashier_id = $1 AND s.status = $2", cashierID, models.ShiftStatusOpen), &s)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("no open shift found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Open starts a shift. A cashier can only have one open shift, and a register
+// only one open shift at a time.
+func (repo *ShiftRepository) Open(shift *models.Shift) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var busy string
+	err = tx.QueryRow("SELECT register_id FROM shifts WHERE (cashier_id = $1 OR register_id = $2) AND status = $3 LIMIT 1",
+		shift.CashierID, shift.RegisterID, models.ShiftStatusOpen).Scan(&busy)
+	if err == nil {
+		return fmt.Errorf("a shift is already open for this cashier or on register %s", busy)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	shift.Status = models.ShiftStatusOpen
+	err = tx.QueryRow("INSERT INTO shifts (cashier_id, register_id, status, opening_float, opened_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		shift.CashierID, shift.RegisterID, shift.Status, shift.OpeningFloat, shift.OpenedAt).Scan(&shift.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Report builds the X report of a shift from its transactions and refunds.
+func (repo *ShiftRepository) Report(id int) (*models.ShiftReport, error) {
+	shift, err := repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := shiftReport(repo.db, *shift)
+	if err != nil {
+		return nil, err
+	}
+	if shift.Status == models.ShiftStatusClosed {
+		// Keep the figures recorded at closing
+		report.ExpectedCash = *shift.ExpectedCash
+		report.CountedCash = shift.CountedCash
+		report.Difference = shift.Difference
+	}
+
+	return report, nil
+}
+
+// Close records the counted cash against the expected cash and closes the shift.
+// The shift row is locked so no checkout can be added to it while closing.
+func (repo *ShiftRepository) Close(id int, countedCash int, notes string) (*models.ShiftReport, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var shift models.Shift
+	err = scanShift(tx.QueryRow(shiftSelect+" WHERE s.id = $1 FOR UPDATE OF s", id), &shift)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("shift id %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if shift.Status != models.ShiftStatusOpen {
+		return nil, fmt.Errorf("shift id %d is already closed", id)
+	}
+
+	report, err := shiftReport(tx, shift)
+	if err != nil {
+		return nil, err
+	}
+
+	closedAt := models.GetCurrentTime()
+	difference := countedCash - report.ExpectedCash
+	_, err = tx.Exec("UPDATE shifts SET status = $1, expected_cash = $2, counted_cash = $3, difference = $4, notes = $5, closed_at = $6 WHERE id = $7",
+		models.ShiftStatusClosed, report.ExpectedCash, countedCash, difference, notes, closedAt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	report.Shift.Status = models.ShiftStatusClosed
+	report.Shift.ExpectedCash = &report.ExpectedCash
+	report.Shift.CountedCash = &countedCash
+	report.Shift.Difference = &difference
+	report.Shift.Notes = notes
+	report.Shift.ClosedAt = &closedAt
+	report.CountedCash = &countedCash
+	report.Difference = &difference
+
+	return report, nil
+}
+
+// shiftReport totals the sales, payments and refunds of a shift. The cash part
+// of refunds issued during a shift is paid out of its drawer.
+func shiftReport(q queryer, shift models.Shift) (*models.ShiftReport, error) {
+	report := models.ShiftReport{Shift: shift}
+
+	err := q.QueryRow("SELECT COUNT(*), COALESCE(SUM(total_amount), 0) FROM transactions WHERE shift_id = $1", shift.ID).
+		Scan(&report.TotalTransaksi, &report.TotalSales)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`
+		SELECT p.method, COUNT(*), COALESCE(SUM(p.amount), 0)
+		FROM payments p
+		JOIN transactions t ON p.transaction_id = t.id
+		WHERE t.shift_id = $1
+		GROUP BY p.method
+		ORDER BY p.method`, shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report.PaymentMethods = make([]models.PaymentMethodSummary, 0)
+	for rows.Next() {
+		var pm models.PaymentMethodSummary
+		if err := rows.Scan(&pm.Method, &pm.Count, &pm.Total); err != nil {
+			return nil, err
+		}
+		report.PaymentMethods = append(report.PaymentMethods, pm)
+		if pm.Method == models.PaymentMethodCash {
+			report.CashIn = pm.Total
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// A refund is paid back in the same mix as the sale was paid, so only the
+	// cash share of each refund leaves the drawer.
+	err = q.QueryRow(`
+		SELECT COALESCE(SUM(r.amount * c.cash / NULLIF(t.total_amount, 0)), 0)
+		FROM refunds r
+		JOIN transactions t ON r.transaction_id = t.id
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM(p.amount), 0) AS cash
+			FROM payments p
+			WHERE p.transaction_id = t.id AND p.method = $2
+		) c
+		WHERE r.shift_id = $1`, shift.ID, models.PaymentMethodCash).Scan(&report.CashRefunds)
+	if err != nil {
+		return nil, err
+	}
+
+	report.ExpectedCash = shift.OpeningFloat + report.CashIn - report.CashRefunds
+	return &report, nil
+}
+
+// openShift returns the open shift of the cashier inside tx, locked against
+// closing until tx ends.
+func openShift(tx *sql.Tx, cashierID int) (*models.Shift, error) {
+	var s models.Shift
+	err := tx.QueryRow("SELECT id, register_id FROM shifts WHERE cashier_id = $1 AND status = $2 FOR SHARE", cashierID, models.ShiftStatusOpen).
+		Scan(&s.ID, &s.RegisterID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"kasir-api/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var shiftColumns = []string{"id", "cashier_id", "username", "register_id", "status", "opening_float", "expected_cash", "counted_cash", "difference", "notes", "opened_at", "closed_at"}
+
+func TestCloseShift_ReconcilesCash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewShiftRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM shifts s LEFT JOIN users u ON s.cashier_id = u.id WHERE s.id = \\$1 FOR UPDATE OF s").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(shiftColumns).
+			AddRow(2, 3, "siti", "KASIR-1", "open", 100000, nil, nil, nil, "", now, nil))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\), COALESCE\\(SUM\\(total_amount\\), 0\\) FROM transactions WHERE shift_id = \\$1").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "total"}).AddRow(3, 80000))
+	mock.ExpectQuery("FROM payments p").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"method", "count", "total"}).
+			AddRow("cash", 2, 50000).
+			AddRow("qris", 1, 30000))
+	mock.ExpectQuery("FROM refunds r JOIN transactions t ON r.transaction_id = t.id CROSS JOIN LATERAL").
+		WithArgs(2, models.PaymentMethodCash).
+		WillReturnRows(sqlmock.NewRows([]string{"refund"}).AddRow(10000))
+	// expected 100000 + 50000 - 10000 = 140000, counted 139000
+	mock.ExpectExec("UPDATE shifts SET status").
+		WithArgs(models.ShiftStatusClosed, 140000, 139000, -1000, "kurang seribu", sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	report, err := repo.Close(2, 139000, "kurang seribu")
+	if err != nil {
+		t.Fatalf("error was not expected while closing shift: %s", err)
+	}
+
+	if report.ExpectedCash != 140000 || *report.Difference != -1000 {
+		t.Errorf("expected cash 140000 with difference -1000, got %d and %d", report.ExpectedCash, *report.Difference)
+	}
+	if report.Shift.Status != models.ShiftStatusClosed || report.TotalSales != 80000 {
+		t.Errorf("expected closed shift with sales 80000, got %+v", report)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCloseShift_AlreadyClosed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewShiftRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM shifts s").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(shiftColumns).
+			AddRow(2, 3, "siti", "KASIR-1", "closed", 100000, 140000, 140000, 0, "", now, now))
+	mock.ExpectRollback()
+
+	if _, err := repo.Close(2, 140000, ""); err == nil {
+		t.Fatal("expected error when closing a closed shift")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
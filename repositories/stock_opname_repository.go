@@ -0,0 +1,305 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"kasir-api/models"
+)
+
+type StockOpnameRepository struct {
+	db *sql.DB
+}
+
+func NewStockOpnameRepository(db *sql.DB) *StockOpnameRepository {
+	return &StockOpnameRepository{db: db}
+}
+
+const stockOpnameSelect = `SELECT o.id, o.status, COALESCE(o.notes, ''), o.created_by, COALESCE(u.username, ''), o.committed_by,
+o.started_at, o.finished_at
+FROM stock_opnames o
+LEFT JOIN users u ON o.created_by = u.id`
+
+func scanStockOpname(scanner interface{ Scan(...any) error }, o *models.StockOpname) error {
+	return scanner.Scan(&o.ID, &o.Status, &o.Notes, &o.CreatedBy, &o.CreatedByName, &o.CommittedBy, &o.StartedAt, &o.FinishedAt)
+}
+
+func (repo *StockOpnameRepository) GetAll(status string) ([]models.StockOpname, error) {
+	query := stockOpnameSelect
+	args := make([]any, 0)
+	if status != "" {
+		query += " WHERE o.status = $1"
+		args = append(args, status)
+	}
+
+	rows, err := repo.db.Query(query+" ORDER BY o.started_at DESC, o.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	opnames := make([]models.StockOpname, 0)
+	for rows.Next() {
+		var o models.StockOpname
+		if err := scanStockOpname(rows, &o); err != nil {
+			return nil, err
+		}
+		opnames = append(opnames, o)
+	}
+
+	return opnames, rows.Err()
+}
+
+func (repo *StockOpnameRepository) GetByID(id int) (*models.StockOpname, error) {
+	var o models.StockOpname
+	err := scanStockOpname(repo.db.QueryRow(stockOpnameSelect+" WHERE o.id = $1", id), &o)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("stock opname id %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+// Start opens a new count session. Only one session can be open at a time.
+func (repo *StockOpnameRepository) Start(o *models.StockOpname) error {
+	var openID int
+	err := repo.db.QueryRow("SELECT id FROM stock_opnames WHERE status = $1", models.StockOpnameStatusOpen).Scan(&openID)
+	if err == nil {
+		return fmt.Errorf("stock opname id %d is already open", openID)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	o.Status = models.StockOpnameStatusOpen
+	return repo.db.QueryRow("INSERT INTO stock_opnames (status, notes, created_by, started_at) VALUES ($1, $2, $3, $4) RETURNING id",
+		o.Status, o.Notes, o.CreatedBy, o.StartedAt).Scan(&o.ID)
+}
+
+// SaveCounts records counted quantities in an open session together with the
+// system stock at that moment, replacing earlier counts of the same products.
+func (repo *StockOpnameRepository) SaveCounts(id int, counts []models.StockOpnameCount) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenStockOpname(tx, id); err != nil {
+		return err
+	}
+
+	productIDs := make([]int, 0, len(counts))
+	for _, c := range counts {
+		productIDs = append(productIDs, c.ProductID)
+	}
+	missing, err := missingProduct(tx, productIDs)
+	if err != nil {
+		return err
+	}
+	if missing != 0 {
+		return fmt.Errorf("product id %d not found", missing)
+	}
+
+	countedAt := models.GetCurrentTime()
+	for _, c := range counts {
+		_, err := tx.Exec(`INSERT INTO stock_opname_items (opname_id, product_id, counted_quantity, system_stock, counted_at)
+SELECT $1, id, $3, stock, $4 FROM products WHERE id = $2
+ON CONFLICT (opname_id, product_id) DO UPDATE SET counted_quantity = EXCLUDED.counted_quantity, system_stock = EXCLUDED.system_stock, counted_at = EXCLUDED.counted_at`,
+			id, c.ProductID, *c.CountedQuantity, countedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Report returns the variance preview of an open session or the recorded
+// variances of a committed one.
+func (repo *StockOpnameRepository) Report(id int) (*models.StockOpnameReport, error) {
+	opname, err := repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return stockOpnameReport(repo.db, *opname)
+}
+
+// Commit applies the variance of every counted product (counted quantity minus the
+// system stock when it was counted) to its current stock, so sales and receipts
+// after the count are kept. Variances are recorded as stock_opname movements and
+// the session is closed, all in one transaction. Products are locked in id order
+// so concurrent checkouts wait.
+func (repo *StockOpnameRepository) Commit(id int, userID *int) (*models.StockOpnameReport, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenStockOpname(tx, id); err != nil {
+		return nil, err
+	}
+
+	type countedItem struct {
+		productID, counted, systemStock, stock, cost int
+	}
+	rows, err := tx.Query(`SELECT i.product_id, i.counted_quantity, COALESCE(i.system_stock, p.stock), p.stock, p.cost_price
+FROM stock_opname_items i
+JOIN products p ON i.product_id = p.id
+WHERE i.opname_id = $1
+ORDER BY i.product_id
+FOR UPDATE OF p`, id)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]countedItem, 0)
+	for rows.Next() {
+		var item countedItem
+		if err := rows.Scan(&item.productID, &item.counted, &item.systemStock, &item.stock, &item.cost); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("stock opname id %d has no counted items", id)
+	}
+
+	for _, item := range items {
+		variance := item.counted - item.systemStock
+		stockAfter := item.stock + variance
+		if stockAfter < 0 {
+			return nil, fmt.Errorf("stock of product id %d would go below zero, count it again", item.productID)
+		}
+		if variance != 0 {
+			if _, err := tx.Exec("UPDATE products SET stock = $1 WHERE id = $2", stockAfter, item.productID); err != nil {
+				return nil, err
+			}
+			err := recordStockMovement(tx, &models.StockMovement{
+				ProductID:   item.productID,
+				Type:        models.StockMovementStockOpname,
+				Quantity:    variance,
+				StockAfter:  stockAfter,
+				Reason:      fmt.Sprintf("stock opname #%d", id),
+				ReferenceID: &id,
+				UserID:      userID,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, err := tx.Exec("UPDATE stock_opname_items SET system_stock = $1, variance = $2, unit_cost = $3 WHERE opname_id = $4 AND product_id = $5",
+			item.systemStock, variance, item.cost, id, item.productID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE stock_opnames SET status = $1, committed_by = $2, finished_at = $3 WHERE id = $4",
+		models.StockOpnameStatusCommitted, userID, models.GetCurrentTime(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	var opname models.StockOpname
+	if err := scanStockOpname(tx.QueryRow(stockOpnameSelect+" WHERE o.id = $1", id), &opname); err != nil {
+		return nil, err
+	}
+	report, err := stockOpnameReport(tx, opname)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Cancel closes an open session without touching stock.
+func (repo *StockOpnameRepository) Cancel(id int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenStockOpname(tx, id); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE stock_opnames SET status = $1, finished_at = $2 WHERE id = $3",
+		models.StockOpnameStatusCancelled, models.GetCurrentTime(), id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockOpenStockOpname locks the session row and checks that it is still open.
+func lockOpenStockOpname(tx *sql.Tx, id int) error {
+	var status string
+	err := tx.QueryRow("SELECT status FROM stock_opnames WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("stock opname id %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.StockOpnameStatusOpen {
+		return fmt.Errorf("stock opname id %d is already %s", id, status)
+	}
+
+	return nil
+}
+
+// stockOpnameReport lists the counted items with their variances. Items of a
+// committed session use the cost recorded at commit.
+func stockOpnameReport(q queryer, opname models.StockOpname) (*models.StockOpnameReport, error) {
+	rows, err := q.Query(`SELECT i.product_id, p.name, i.counted_quantity, COALESCE(i.system_stock, p.stock), COALESCE(i.unit_cost, p.cost_price), i.counted_at
+FROM stock_opname_items i
+JOIN products p ON i.product_id = p.id
+WHERE i.opname_id = $1
+ORDER BY i.product_id`, opname.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := models.StockOpnameReport{Opname: opname, Items: make([]models.StockOpnameItem, 0)}
+	for rows.Next() {
+		var item models.StockOpnameItem
+		err := rows.Scan(&item.ProductID, &item.ProductName, &item.CountedQuantity, &item.SystemStock, &item.UnitCost, &item.CountedAt)
+		if err != nil {
+			return nil, err
+		}
+		item.Variance = item.CountedQuantity - item.SystemStock
+		item.VarianceValue = item.Variance * item.UnitCost
+
+		report.ProductsCounted++
+		if item.Variance < 0 {
+			report.ProductsVariance++
+			report.ShrinkageQuantity -= item.Variance
+			report.ShrinkageValue -= item.VarianceValue
+		} else if item.Variance > 0 {
+			report.ProductsVariance++
+			report.SurplusQuantity += item.Variance
+			report.SurplusValue += item.VarianceValue
+		}
+		report.NetVarianceValue += item.VarianceValue
+		report.Items = append(report.Items, item)
+	}
+
+	return &report, rows.Err()
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"kasir-api/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCommitStockOpname_AppliesVariances(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewStockOpnameRepository(db)
+	now := time.Now()
+	userID := 1
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM stock_opnames WHERE id = \\$1 FOR UPDATE").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.StockOpnameStatusOpen))
+	mock.ExpectQuery("FROM stock_opname_items i JOIN products p ON i.product_id = p.id WHERE i.opname_id = \\$1 ORDER BY i.product_id FOR UPDATE OF p").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "counted_quantity", "system_stock", "stock", "cost_price"}).
+			AddRow(1, 8, 10, 7, 2500).
+			AddRow(2, 40, 40, 38, 2000))
+	// Product 1 was two short when counted and sold three since, so 7 - 2 is left;
+	// product 2 matched its count and its later sales are not a variance
+	mock.ExpectExec("UPDATE products SET stock = \\$1 WHERE id = \\$2").
+		WithArgs(5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, models.StockMovementStockOpname, -2, 5, "stock opname #5", 5, 1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectExec("UPDATE stock_opname_items SET system_stock").
+		WithArgs(10, -2, 2500, 5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE stock_opname_items SET system_stock").
+		WithArgs(40, 0, 2000, 5, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE stock_opnames SET status").
+		WithArgs(models.StockOpnameStatusCommitted, 1, sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM stock_opnames o").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "notes", "created_by", "username", "committed_by", "started_at", "finished_at"}).
+			AddRow(5, models.StockOpnameStatusCommitted, "", 1, "admin", 1, now, now))
+	mock.ExpectQuery("FROM stock_opname_items i JOIN products p").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "name", "counted_quantity", "system_stock", "unit_cost", "counted_at"}).
+			AddRow(1, "Indomie", 8, 10, 2500, now).
+			AddRow(2, "Vit 1000ml", 40, 40, 2000, now))
+	mock.ExpectCommit()
+
+	report, err := repo.Commit(5, &userID)
+	if err != nil {
+		t.Fatalf("error was not expected while committing stock opname: %s", err)
+	}
+
+	if report.ProductsCounted != 2 || report.ProductsVariance != 1 {
+		t.Errorf("expected 2 counted and 1 with variance, got %d and %d", report.ProductsCounted, report.ProductsVariance)
+	}
+	if report.ShrinkageQuantity != 2 || report.ShrinkageValue != 5000 || report.NetVarianceValue != -5000 {
+		t.Errorf("expected shrinkage 2 worth 5000, got %+v", report)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCommitStockOpname_RejectsStockSoldBelowCount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewStockOpnameRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM stock_opnames WHERE id = \\$1 FOR UPDATE").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.StockOpnameStatusOpen))
+	// Counted 2 of 10, but 9 were sold since: the count cannot be right
+	mock.ExpectQuery("FROM stock_opname_items i JOIN products p").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "counted_quantity", "system_stock", "stock", "cost_price"}).
+			AddRow(1, 2, 10, 1, 2500))
+	mock.ExpectRollback()
+
+	_, err = repo.Commit(5, nil)
+	if err == nil || err.Error() != "stock of product id 1 would go below zero, count it again" {
+		t.Fatalf("expected count again error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSaveStockOpnameCounts_RejectsCommittedSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewStockOpnameRepository(db)
+	counted := 3
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM stock_opnames").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.StockOpnameStatusCommitted))
+	mock.ExpectRollback()
+
+	err = repo.SaveCounts(5, []models.StockOpnameCount{{ProductID: 1, CountedQuantity: &counted}})
+	if err == nil || err.Error() != "stock opname id 5 is already committed" {
+		t.Fatalf("expected already committed error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
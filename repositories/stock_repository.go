@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/models"
+)
+
+type StockRepository struct {
+	db *sql.DB
+}
+
+func NewStockRepository(db *sql.DB) *StockRepository {
+	return &StockRepository{db: db}
+}
+
+// GetMovements returns a page of the product's stock ledger, newest first.
+func (repo *StockRepository) GetMovements(productID, page, limit int) ([]models.StockMovement, int, error) {
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM stock_movements WHERE product_id = $1", productID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT m.id, m.product_id, m.type, m.quantity, m.stock_after, COALESCE(m.reason, ''), m.reference_id, m.user_id,
+COALESCE(u.username, ''), m.created_at
+FROM stock_movements m
+LEFT JOIN users u ON m.user_id = u.id
+WHERE m.product_id = $1
+ORDER BY m.created_at DESC, m.id DESC
+LIMIT $2 OFFSET $3`
+
+	rows, err := repo.db.Query(query, productID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	movements := make([]models.StockMovement, 0)
+	for rows.Next() {
+		var m models.StockMovement
+		err := rows.Scan(&m.ID, &m.ProductID, &m.Type, &m.Quantity, &m.StockAfter, &m.Reason, &m.ReferenceID, &m.UserID,
+			&m.Username, &m.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		movements = append(movements, m)
+	}
+
+	return movements, total, rows.Err()
+}
+
+// Adjust changes the product's stock by m.Quantity in one statement and records
+// the movement. The change is rejected if it would take the stock below zero.
+func (repo *StockRepository) Adjust(m *models.StockMovement) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow("UPDATE products SET stock = stock + $1 WHERE id = $2 AND stock + $1 >= 0 RETURNING stock",
+		m.Quantity, m.ProductID).Scan(&m.StockAfter)
+	if err == sql.ErrNoRows {
+		var stock int
+		err = tx.QueryRow("SELECT stock FROM products WHERE id = $1", m.ProductID).Scan(&stock)
+		if err == sql.ErrNoRows {
+			return errors.New("produk tidak ditemukan")
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("stock cannot go below zero (current stock %d, change %d)", stock, m.Quantity)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := recordStockMovement(tx, m); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// recordStockMovement appends m to the ledger inside tx. Callers change
+// products.stock in the same transaction and pass the resulting level as StockAfter.
+func recordStockMovement(tx *sql.Tx, m *models.StockMovement) error {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = models.GetCurrentTime()
+	}
+	var reason *string
+	if m.Reason != "" {
+		reason = &m.Reason
+	}
+
+	return tx.QueryRow(`INSERT INTO stock_movements (product_id, type, quantity, stock_after, reason, reference_id, user_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		m.ProductID, m.Type, m.Quantity, m.StockAfter, reason, m.ReferenceID, m.UserID, m.CreatedAt).Scan(&m.ID)
+}
+
+// userRef turns a user ID from the login token into a nullable column value.
+func userRef(userID int) *int {
+	if userID <= 0 {
+		return nil
+	}
+	return &userID
+}
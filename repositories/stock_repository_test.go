@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"database/sql"
+	"kasir-api/models"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAdjustStock_RecordsMovement(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewStockRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2 AND stock \\+ \\$1 >= 0 RETURNING stock").
+		WithArgs(-2, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(8))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(4, models.StockMovementAdjustment, -2, 8, "damaged", nil, 3, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	userID := 3
+	movement := models.StockMovement{ProductID: 4, Type: models.StockMovementAdjustment, Quantity: -2, Reason: "damaged", UserID: &userID}
+	if err := repo.Adjust(&movement); err != nil {
+		t.Fatalf("error was not expected while adjusting stock: %s", err)
+	}
+	if movement.StockAfter != 8 {
+		t.Errorf("expected stock after 8, got %d", movement.StockAfter)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAdjustStock_BelowZero(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewStockRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET stock = stock").
+		WithArgs(-5, 4).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT stock FROM products WHERE id = \\$1").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(3))
+	mock.ExpectRollback()
+
+	movement := models.StockMovement{ProductID: 4, Type: models.StockMovementAdjustment, Quantity: -5, Reason: "expired"}
+	err = repo.Adjust(&movement)
+	if err == nil || !strings.Contains(err.Error(), "below zero") {
+		t.Fatalf("expected below zero error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
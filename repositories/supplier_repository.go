@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"kasir-api/models"
+)
+
+type SupplierRepository struct {
+	db *sql.DB
+}
+
+func NewSupplierRepository(db *sql.DB) *SupplierRepository {
+	return &SupplierRepository{db: db}
+}
+
+const supplierColumns = "id, name, COALESCE(contact_name, ''), COALESCE(phone, ''), COALESCE(email, ''), COALESCE(address, '')"
+
+func scanSupplier(scanner interface{ Scan(...any) error }, s *models.Supplier) error {
+	return scanner.Scan(&s.ID, &s.Name, &s.ContactName, &s.Phone, &s.Email, &s.Address)
+}
+
+func (repo *SupplierRepository) GetAll() ([]models.Supplier, error) {
+	rows, err := repo.db.Query("SELECT " + supplierColumns + " FROM suppliers ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppliers := make([]models.Supplier, 0)
+	for rows.Next() {
+		var s models.Supplier
+		if err := scanSupplier(rows, &s); err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, s)
+	}
+
+	return suppliers, rows.Err()
+}
+
+func (repo *SupplierRepository) GetByID(id int) (*models.Supplier, error) {
+	var s models.Supplier
+	err := scanSupplier(repo.db.QueryRow("SELECT "+supplierColumns+" FROM suppliers WHERE id = $1", id), &s)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("supplier tidak ditemukan")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (repo *SupplierRepository) Create(s *models.Supplier) error {
+	query := "INSERT INTO suppliers (name, contact_name, phone, email, address) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	return repo.db.QueryRow(query, s.Name, s.ContactName, s.Phone, s.Email, s.Address).Scan(&s.ID)
+}
+
+func (repo *SupplierRepository) Update(s *models.Supplier) error {
+	query := "UPDATE suppliers SET name = $1, contact_name = $2, phone = $3, email = $4, address = $5 WHERE id = $6"
+	result, err := repo.db.Exec(query, s.Name, s.ContactName, s.Phone, s.Email, s.Address, s.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.New("supplier tidak ditemukan")
+	}
+
+	return nil
+}
+
+// Delete removes a supplier that has no purchase orders.
+func (repo *SupplierRepository) Delete(id int) error {
+	var orders int
+	if err := repo.db.QueryRow("SELECT COUNT(*) FROM purchase_orders WHERE supplier_id = $1", id).Scan(&orders); err != nil {
+		return err
+	}
+	if orders > 0 {
+		return errors.New("supplier masih dipakai di purchase order")
+	}
+
+	result, err := repo.db.Exec("DELETE FROM suppliers WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errors.New("supplier tidak ditemukan")
+	}
+
+	return nil
+}
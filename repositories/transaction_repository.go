@@ -1,93 +1,314 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"kasir-api/models"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type TransactionRepository interface {
-	CreateTransaction(items []models.CheckoutItem, useLock bool) (*models.Transaction, error)
+	CreateTransaction(req models.CheckoutRequest, useLock bool) (*models.Transaction, error)
 	GetSalesSummary(startDate, endDate time.Time) (*models.SalesSummary, error)
+	GetSalesByGroup(startDate, endDate time.Time, groupBy string) ([]models.SalesGroup, error)
+	GetMargins(startDate, endDate time.Time, groupBy string) ([]models.MarginRow, error)
+	GetTransactions(filter models.TransactionFilter) ([]models.Transaction, int, error)
+	GetTransactionByID(id int) (*models.Transaction, error)
+	RefundTransaction(transactionID int, req models.RefundRequest, void bool) (*models.Refund, error)
 }
 
 type transactionRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	tax models.TaxConfig
 }
 
-func NewTransactionRepository(db *sql.DB) *transactionRepository {
-	return &transactionRepository{db: db}
+func NewTransactionRepository(db *sql.DB, tax models.TaxConfig) *transactionRepository {
+	return &transactionRepository{db: db, tax: tax}
 }
 
-func (repo *transactionRepository) CreateTransaction(items []models.CheckoutItem, useLock bool) (*models.Transaction, error) {
-	tx, err := repo.db.Begin()
+// checkoutTimeout bounds a whole checkout, retries included. A checkout that
+// has not committed by then is rolled back and can never commit later.
+const checkoutTimeout = 10 * time.Second
+
+// CreateTransaction runs the checkout in one DB transaction, starting over when
+// Postgres aborts it on a deadlock or serialization failure.
+func (repo *transactionRepository) CreateTransaction(req models.CheckoutRequest, useLock bool) (*models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkoutTimeout)
+	defer cancel()
+
+	var transaction *models.Transaction
+	err := retryOnConflict(func() error {
+		var err error
+		transaction, err = repo.createTransaction(ctx, req, useLock)
+		return err
+	})
+	if isTimeout(err) {
+		return nil, fmt.Errorf("checkout timed out after %s, try again", checkoutTimeout)
+	}
+	return transaction, err
+}
+
+func (repo *transactionRepository) createTransaction(ctx context.Context, req models.CheckoutRequest, useLock bool) (*models.Transaction, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	totalAmount := 0
-	details := make([]models.TransactionDetail, 0)
+	// The context rolls the transaction back at the deadline; the statement
+	// timeout stops a statement, such as a lock wait, that is still running then
+	deadline, _ := ctx.Deadline()
+	if _, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout = %d", max(time.Until(deadline).Milliseconds(), 1))); err != nil {
+		return nil, err
+	}
 
-	for _, item := range items {
-		var productPrice, stock int
-		var productName string
+	// Every sale belongs to the cashier's open shift, which also fixes the register
+	shift, err := openShift(tx, req.CashierID)
+	if err != nil {
+		return nil, err
+	}
+	if shift == nil {
+		return nil, fmt.Errorf("no open shift for this cashier, open a shift first")
+	}
+	if req.RegisterID != "" && req.RegisterID != shift.RegisterID {
+		return nil, fmt.Errorf("register_id %s does not match the open shift on register %s", req.RegisterID, shift.RegisterID)
+	}
+	req.RegisterID = shift.RegisterID
 
-		query := "SELECT name, price, stock FROM products WHERE id = $1"
+	if req.CartID > 0 {
+		req.Items, req.PromoCode, err = checkoutCart(tx, req.CartID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	items, err := resolveCheckoutItems(tx, req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]models.TransactionDetail, len(items))
+	lines := make([]checkoutLine, len(items))
+	stockAfter := make([]int, len(items))
+	lowStock := make([]models.LowStockEvent, 0)
+
+	// Rows are locked in product ID order, whatever the order of the cart, so two
+	// checkouts of the same products cannot deadlock; details keep the cart order.
+	for _, i := range lockOrder(items) {
+		item := items[i]
+		var productPrice, costPrice, stock, categoryID, reorderQty int
+		var productName, categoryName, baseUnit string
+		var taxExempt bool
+		var categoryTaxRate *float64
+		var reorderPoint, unitFactor, unitPrice *int
+		var archived bool
+
+		query := `SELECT p.name, p.price, p.cost_price, p.stock, COALESCE(p.category_id, 0), COALESCE(c.name, ''), p.tax_exempt, c.tax_rate, p.reorder_point, p.reorder_qty,
+p.archived_at IS NOT NULL, p.base_unit, u.factor, u.price
+FROM products p
+LEFT JOIN categories c ON p.category_id = c.id
+LEFT JOIN product_units u ON u.product_id = p.id AND u.unit = $2
+WHERE p.id = $1`
 		if useLock {
-			query += " FOR UPDATE"
+			query += " FOR UPDATE OF p"
 		}
 
-		err := tx.QueryRow(query, item.ProductID).Scan(&productName, &productPrice, &stock)
+		err := tx.QueryRow(query, item.ProductID, item.Unit).Scan(&productName, &productPrice, &costPrice, &stock, &categoryID, &categoryName, &taxExempt, &categoryTaxRate,
+			&reorderPoint, &reorderQty, &archived, &baseUnit, &unitFactor, &unitPrice)
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("product id %d not found", item.ProductID)
 		}
 		if err != nil {
 			return nil, err
 		}
+		if archived {
+			return nil, fmt.Errorf("product %s (id: %d) is archived and cannot be sold", productName, item.ProductID)
+		}
 
-		if stock < item.Quantity {
-			return nil, fmt.Errorf("product %s (id: %d) has insufficient stock", productName, item.ProductID)
+		// Packs are priced on their own; stock is always taken in base units
+		unit, factor := baseUnit, 1
+		if unitFactor != nil {
+			unit, factor, productPrice = item.Unit, *unitFactor, *unitPrice
+		} else if item.Unit != "" && item.Unit != baseUnit {
+			return nil, fmt.Errorf("invalid unit %q for product %s (id: %d)", item.Unit, productName, item.ProductID)
 		}
+		baseQty := item.Quantity * factor
 
-		subtotal := productPrice * item.Quantity
-		totalAmount += subtotal
+		if stock < baseQty {
+			return nil, fmt.Errorf("product %s (id: %d) has insufficient stock", productName, item.ProductID)
+		}
 
 		// atomic update with check
-		res, err := tx.Exec("UPDATE products SET stock = stock - $1 WHERE id = $2 AND stock >= $1", item.Quantity, item.ProductID)
+		var newStock int
+		err = tx.QueryRow("UPDATE products SET stock = stock - $1 WHERE id = $2 AND stock >= $1 RETURNING stock", baseQty, item.ProductID).Scan(&newStock)
+		if err == sql.ErrNoRows {
+			// This might happen if race condition occurred and stock wasn't locked, or if stock changed between read and update
+			return nil, fmt.Errorf("failed to update stock for product %s (id: %d), possibly insufficient stock", productName, item.ProductID)
+		}
 		if err != nil {
 			return nil, err
 		}
 
-		rowsAffected, err := res.RowsAffected()
+		// Stock held for other carts is not for sale; the cart being checked out
+		// may use its own. Reservations are read only now that the update holds
+		// the row lock Park takes, so one parked meanwhile is always seen.
+		reserved, err := reservedStock(tx, item.ProductID, req.CartID)
 		if err != nil {
 			return nil, err
 		}
+		if available := availableStock(newStock+baseQty, reserved); available < baseQty {
+			return nil, fmt.Errorf("product %s (id: %d) has insufficient stock, %d reserved for other carts", productName, item.ProductID, reserved)
+		}
+		stockAfter[i] = newStock
 
-		if rowsAffected == 0 {
-			// This might happen if race condition occurred and stock wasn't locked, or if stock changed between read and update
-			return nil, fmt.Errorf("failed to update stock for product %s (id: %d), possibly insufficient stock", productName, item.ProductID)
+		// Alert only when this sale crosses the reorder point, not on every sale below it
+		if reorderPoint != nil && newStock <= *reorderPoint && newStock+baseQty > *reorderPoint {
+			lowStock = append(lowStock, models.LowStockEvent{
+				ProductID:    item.ProductID,
+				ProductName:  productName,
+				Stock:        newStock,
+				ReorderPoint: *reorderPoint,
+				ReorderQty:   reorderQty,
+			})
 		}
 
-		details = append(details, models.TransactionDetail{
-			ProductID:   item.ProductID,
-			ProductName: productName,
-			Quantity:    item.Quantity,
-			Subtotal:    subtotal,
-		})
+		details[i] = models.TransactionDetail{
+			ProductID:    item.ProductID,
+			ProductName:  productName,
+			CategoryName: categoryName,
+			Unit:         unit,
+			UnitFactor:   factor,
+			UnitPrice:    productPrice,
+			Quantity:     item.Quantity,
+			UnitCost:     costPrice * factor,
+		}
+		if categoryID > 0 {
+			details[i].CategoryID = &categoryID
+		}
+		// Per-unit product discounts count base units, so a dus gets them per pcs
+		lines[i] = checkoutLine{
+			ProductID:  item.ProductID,
+			CategoryID: categoryID,
+			Quantity:   baseQty,
+			Gross:      productPrice * item.Quantity,
+			TaxRate:    lineTaxRate(repo.tax, taxExempt, categoryTaxRate),
+		}
+	}
+
+	createdAt := models.GetCurrentTime()
+
+	discounts, err := activeDiscounts(tx, createdAt, req.PromoCode)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := applyDiscounts(lines, discounts, req.PromoCode)
+	if err != nil {
+		return nil, err
+	}
+
+	subtotal, taxAmount, serviceCharge := applyTax(lines, repo.tax)
+
+	grossAmount, discountAmount := 0, 0
+	for i, line := range lines {
+		details[i].GrossSubtotal = line.Gross
+		details[i].DiscountAmount = line.Discount
+		details[i].Subtotal = line.net()
+		details[i].TaxRate = line.TaxRate
+		details[i].TaxAmount = line.Tax
+		details[i].LineTotal = line.Total
+		grossAmount += line.Gross
+		discountAmount += line.Discount
+	}
+	totalAmount := subtotal + taxAmount + serviceCharge
+
+	payments, change, err := settlePayments(totalAmount, req.Payments)
+	if err != nil {
+		return nil, err
+	}
+
+	var cashierID *int
+	if req.CashierID > 0 {
+		cashierID = &req.CashierID
 	}
 
 	var transactionID int
-	err = tx.QueryRow("INSERT INTO transactions (total_amount, created_at) VALUES ($1, $2) RETURNING id", totalAmount, models.GetCurrentTime()).Scan(&transactionID)
+	err = tx.QueryRow(`INSERT INTO transactions (gross_amount, discount_amount, subtotal, tax_amount, service_charge, total_amount, tax_inclusive, cashier_id, register_id, shift_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+		grossAmount, discountAmount, subtotal, taxAmount, serviceCharge, totalAmount, repo.tax.Inclusive, cashierID, req.RegisterID, shift.ID, createdAt).Scan(&transactionID)
 	if err != nil {
 		return nil, err
 	}
 
 	for i := range details {
 		details[i].TransactionID = transactionID
-		err = tx.QueryRow("INSERT INTO transaction_details (transaction_id, product_id, quantity, subtotal) VALUES ($1, $2, $3, $4) RETURNING id",
-			transactionID, details[i].ProductID, details[i].Quantity, details[i].Subtotal).Scan(&details[i].ID)
+		err = tx.QueryRow(`INSERT INTO transaction_details (transaction_id, product_id, product_name, category_id, category_name, unit, unit_factor, unit_price, quantity,
+gross_subtotal, discount_amount, subtotal, tax_rate, tax_amount, line_total, unit_cost)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id`,
+			transactionID, details[i].ProductID, details[i].ProductName, details[i].CategoryID, details[i].CategoryName, details[i].Unit, details[i].UnitFactor, details[i].UnitPrice,
+			details[i].Quantity, details[i].GrossSubtotal, details[i].DiscountAmount, details[i].Subtotal,
+			details[i].TaxRate, details[i].TaxAmount, details[i].LineTotal, details[i].UnitCost).Scan(&details[i].ID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = recordStockMovement(tx, &models.StockMovement{
+			ProductID:   details[i].ProductID,
+			Type:        models.StockMovementSale,
+			Quantity:    -details[i].Quantity * details[i].UnitFactor,
+			StockAfter:  stockAfter[i],
+			ReferenceID: &transactionID,
+			UserID:      cashierID,
+			CreatedAt:   createdAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	appliedDiscounts := make([]models.AppliedDiscount, 0, len(applied))
+	for _, a := range applied {
+		ad := models.AppliedDiscount{
+			TransactionID: transactionID,
+			DiscountID:    a.Discount.ID,
+			Name:          a.Discount.Name,
+			Scope:         a.Discount.Scope,
+			Amount:        a.Amount,
+		}
+		if a.Line >= 0 {
+			ad.TransactionDetailID = &details[a.Line].ID
+		}
+		err = tx.QueryRow("INSERT INTO transaction_discounts (transaction_id, transaction_detail_id, discount_id, name, scope, amount) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+			transactionID, ad.TransactionDetailID, ad.DiscountID, ad.Name, ad.Scope, ad.Amount).Scan(&ad.ID)
+		if err != nil {
+			return nil, err
+		}
+		appliedDiscounts = append(appliedDiscounts, ad)
+	}
+
+	totalPaid := 0
+	for i := range payments {
+		payments[i].TransactionID = transactionID
+		payments[i].CreatedAt = createdAt
+		err = tx.QueryRow("INSERT INTO payments (transaction_id, method, amount, amount_tendered, change_amount, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+			transactionID, payments[i].Method, payments[i].Amount, payments[i].AmountTendered, payments[i].Change, payments[i].CreatedAt).Scan(&payments[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		totalPaid += payments[i].AmountTendered
+	}
+
+	// The sale uses up whatever the cart had reserved and closes the cart
+	if req.CartID > 0 {
+		if _, err := tx.Exec("DELETE FROM stock_reservations WHERE cart_id = $1", req.CartID); err != nil {
+			return nil, err
+		}
+		_, err := tx.Exec("UPDATE carts SET status = $1, transaction_id = $2, updated_at = $3 WHERE id = $4",
+			models.CartStatusCheckedOut, transactionID, createdAt, req.CartID)
 		if err != nil {
 			return nil, err
 		}
@@ -97,32 +318,170 @@ func (repo *transactionRepository) CreateTransaction(items []models.CheckoutItem
 		return nil, err
 	}
 
+	for i := range lowStock {
+		lowStock[i].TransactionID = transactionID
+		lowStock[i].OccurredAt = createdAt
+	}
+
 	return &models.Transaction{
-		ID:          transactionID,
-		TotalAmount: totalAmount,
-		CreatedAt:   models.GetCurrentTime(),
-		Details:     details,
+		ID:             transactionID,
+		GrossAmount:    grossAmount,
+		DiscountAmount: discountAmount,
+		Subtotal:       subtotal,
+		TaxAmount:      taxAmount,
+		ServiceCharge:  serviceCharge,
+		TotalAmount:    totalAmount,
+		TaxInclusive:   repo.tax.Inclusive,
+		Status:         models.TransactionStatusCompleted,
+		CashierID:      cashierID,
+		CashierName:    req.CashierName,
+		RegisterID:     req.RegisterID,
+		ShiftID:        &shift.ID,
+		CreatedAt:      createdAt,
+		Details:        details,
+		Discounts:      appliedDiscounts,
+		Payments:       payments,
+		TotalPaid:      totalPaid,
+		Change:         change,
+		LowStock:       lowStock,
 	}, nil
 }
 
+// resolveCheckoutItems looks up the products of items given by barcode and
+// merges lines for the same product and unit into one, in the order they first
+// appear.
+func resolveCheckoutItems(q queryer, items []models.CheckoutItem) ([]models.CheckoutItem, error) {
+	type lineKey struct {
+		productID int
+		unit      string
+	}
+	merged := make([]models.CheckoutItem, 0, len(items))
+	index := make(map[lineKey]int, len(items))
+	for _, item := range items {
+		if item.ProductID == 0 && item.Barcode != "" {
+			err := q.QueryRow("SELECT product_id FROM product_barcodes WHERE code = $1", item.Barcode).Scan(&item.ProductID)
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("barcode %s not found", item.Barcode)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		key := lineKey{item.ProductID, item.Unit}
+		if i, ok := index[key]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[key] = len(merged)
+		item.Barcode = ""
+		merged = append(merged, item)
+	}
+	return merged, nil
+}
+
+// lockOrder returns the indexes of items sorted by product ID, the order in
+// which every checkout locks product rows.
+func lockOrder(items []models.CheckoutItem) []int {
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return items[order[a]].ProductID < items[order[b]].ProductID })
+	return order
+}
+
+// settlePayments checks that the tendered lines cover the total and works out the change.
+// Only cash can be overpaid: non-cash tenders together may not exceed the total, and any
+// change is taken back from the cash lines, last line first.
+func settlePayments(total int, reqs []models.PaymentRequest) ([]models.Payment, int, error) {
+	if len(reqs) == 0 {
+		return nil, 0, fmt.Errorf("payment is required")
+	}
+
+	tendered, nonCash := 0, 0
+	payments := make([]models.Payment, len(reqs))
+	for i, req := range reqs {
+		payments[i] = models.Payment{
+			Method:         req.Method,
+			Amount:         req.AmountTendered,
+			AmountTendered: req.AmountTendered,
+		}
+		tendered += req.AmountTendered
+		if req.Method != models.PaymentMethodCash {
+			nonCash += req.AmountTendered
+		}
+	}
+
+	if tendered < total {
+		return nil, 0, fmt.Errorf("insufficient payment: total %d, tendered %d", total, tendered)
+	}
+	if nonCash > total {
+		return nil, 0, fmt.Errorf("non-cash payments (%d) must not exceed the total amount %d", nonCash, total)
+	}
+
+	change := tendered - total
+	remaining := change
+	for i := len(payments) - 1; i >= 0 && remaining > 0; i-- {
+		if payments[i].Method != models.PaymentMethodCash {
+			continue
+		}
+		c := min(remaining, payments[i].AmountTendered)
+		payments[i].Change = c
+		payments[i].Amount -= c
+		remaining -= c
+	}
+
+	return payments, change, nil
+}
+
 func (repo *transactionRepository) GetSalesSummary(startDate, endDate time.Time) (*models.SalesSummary, error) {
 	var summary models.SalesSummary
 
-	// 1. Total Revenue & Count
-	queryRevenue := "SELECT COUNT(*), COALESCE(SUM(total_amount), 0) FROM transactions WHERE created_at BETWEEN $1 AND $2"
-	err := repo.db.QueryRow(queryRevenue, startDate, endDate).Scan(&summary.TotalTransaksi, &summary.TotalRevenue)
+	// 1. Gross, Discount, Tax, Service, Total Sales & Count
+	queryRevenue := `
+		SELECT COUNT(*), COALESCE(SUM(gross_amount), 0), COALESCE(SUM(discount_amount), 0), COALESCE(SUM(subtotal), 0),
+			COALESCE(SUM(tax_amount), 0), COALESCE(SUM(service_charge), 0), COALESCE(SUM(total_amount), 0)
+		FROM transactions
+		WHERE created_at BETWEEN $1 AND $2`
+	err := repo.db.QueryRow(queryRevenue, startDate, endDate).Scan(&summary.TotalTransaksi, &summary.GrossSales, &summary.TotalDiscount,
+		&summary.TotalSubtotal, &summary.TotalTax, &summary.TotalService, &summary.TotalSales)
 	if err != nil {
 		return nil, err
 	}
 
-	// 2. Best Selling Product
+	// 2. Refunds issued in the period, revenue, tax and service are reported net of them
+	var refundTax, refundService int
+	queryRefund := "SELECT COALESCE(SUM(amount), 0), COALESCE(SUM(tax_amount), 0), COALESCE(SUM(service_charge), 0) FROM refunds WHERE created_at BETWEEN $1 AND $2"
+	err = repo.db.QueryRow(queryRefund, startDate, endDate).Scan(&summary.TotalRefund, &refundTax, &refundService)
+	if err != nil {
+		return nil, err
+	}
+	summary.TotalRevenue = summary.TotalSales - summary.TotalRefund
+	summary.TotalTax -= refundTax
+	summary.TotalService -= refundService
+
+	// 3. Net sales and cost of the goods sold in the period, net of refunded quantities
+	queryMargin := `
+		SELECT ` + marginColumns + `
+		FROM transaction_details td
+		JOIN transactions t ON td.transaction_id = t.id
+		WHERE t.created_at BETWEEN $1 AND $2`
+	var quantity int
+	err = repo.db.QueryRow(queryMargin, startDate, endDate).Scan(&quantity, &summary.NetSales, &summary.TotalCOGS)
+	if err != nil {
+		return nil, err
+	}
+	summary.GrossProfit = summary.NetSales - summary.TotalCOGS
+	summary.GrossMargin = models.Margin(summary.GrossProfit, summary.NetSales)
+
+	// 4. Best Selling Product (net of refunded quantities), named as it was last sold
 	queryBestSeller := `
-		SELECT p.name, COALESCE(SUM(td.quantity), 0) as total_qty
+		SELECT ` + snapshotProductName + `, COALESCE(SUM((td.quantity - td.refunded_quantity) * td.unit_factor), 0) as total_qty
 		FROM transaction_details td
 		JOIN transactions t ON td.transaction_id = t.id
-		JOIN products p ON td.product_id = p.id
 		WHERE t.created_at BETWEEN $1 AND $2
-		GROUP BY p.name
+		GROUP BY td.product_id
 		ORDER BY total_qty DESC
 		LIMIT 1`
 
@@ -134,5 +493,596 @@ func (repo *transactionRepository) GetSalesSummary(startDate, endDate time.Time)
 		return nil, err
 	}
 
+	// 5. Breakdown per payment method
+	queryPayments := `
+		SELECT p.method, COUNT(*), COALESCE(SUM(p.amount), 0)
+		FROM payments p
+		JOIN transactions t ON p.transaction_id = t.id
+		WHERE t.created_at BETWEEN $1 AND $2
+		GROUP BY p.method
+		ORDER BY p.method`
+
+	rows, err := repo.db.Query(queryPayments, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary.PaymentMethods = make([]models.PaymentMethodSummary, 0)
+	for rows.Next() {
+		var pm models.PaymentMethodSummary
+		if err := rows.Scan(&pm.Method, &pm.Count, &pm.Total); err != nil {
+			return nil, err
+		}
+		summary.PaymentMethods = append(summary.PaymentMethods, pm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return &summary, nil
 }
+
+// Base-unit quantity, net sales and COGS of the quantity kept on a
+// transaction_details row td; a partly refunded line only counts for the share
+// that was not returned.
+const (
+	marginNetSales = "(td.line_total - td.tax_amount)::numeric * (td.quantity - td.refunded_quantity) / td.quantity"
+	marginCOGS     = "(td.quantity - td.refunded_quantity) * td.unit_cost"
+	marginColumns  = "COALESCE(SUM((td.quantity - td.refunded_quantity) * td.unit_factor), 0), COALESCE(ROUND(SUM(" + marginNetSales + ")), 0), COALESCE(SUM(" + marginCOGS + "), 0)"
+)
+
+// Reports group on the IDs snapshotted on transaction_details and label each
+// group with the name from its most recent sale, so a renamed product or
+// category stays one row.
+const (
+	snapshotProductName  = "(ARRAY_AGG(td.product_name ORDER BY td.id DESC))[1]"
+	snapshotCategoryName = "(ARRAY_AGG(td.category_name ORDER BY td.id DESC))[1]"
+)
+
+// GetMargins breaks net sales, COGS and gross profit of the goods sold in the
+// period down per product or category, most profitable first, or per day.
+func (repo *transactionRepository) GetMargins(startDate, endDate time.Time, groupBy string) ([]models.MarginRow, error) {
+	var groupColumns, labelColumn string
+	switch groupBy {
+	case models.ReportGroupByProduct:
+		groupColumns, labelColumn = "td.product_id", snapshotProductName+", "
+	case models.ReportGroupByCategory:
+		groupColumns, labelColumn = "td.category_id", snapshotCategoryName+", "
+	case models.ReportGroupByDay:
+		groupColumns = "TO_CHAR(t.created_at, 'YYYY-MM-DD')"
+	default:
+		return nil, fmt.Errorf("invalid group_by %q", groupBy)
+	}
+
+	order := "SUM(" + marginNetSales + ") - SUM(" + marginCOGS + ") DESC, 1"
+	if groupBy == models.ReportGroupByDay {
+		order = "1"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s, %[4]s%[2]s
+		FROM transaction_details td
+		JOIN transactions t ON td.transaction_id = t.id
+		WHERE t.created_at BETWEEN $1 AND $2
+		GROUP BY %[1]s
+		ORDER BY %[3]s`, groupColumns, marginColumns, order, labelColumn)
+
+	rows, err := repo.db.Query(query, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	margins := make([]models.MarginRow, 0)
+	for rows.Next() {
+		var m models.MarginRow
+		switch groupBy {
+		case models.ReportGroupByProduct:
+			err = rows.Scan(&m.ProductID, &m.ProductName, &m.Quantity, &m.NetSales, &m.COGS)
+		case models.ReportGroupByCategory:
+			err = rows.Scan(&m.CategoryID, &m.CategoryName, &m.Quantity, &m.NetSales, &m.COGS)
+		default:
+			err = rows.Scan(&m.Date, &m.Quantity, &m.NetSales, &m.COGS)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.GrossProfit = m.NetSales - m.COGS
+		m.GrossMargin = models.Margin(m.GrossProfit, m.NetSales)
+		margins = append(margins, m)
+	}
+
+	return margins, rows.Err()
+}
+
+// GetSalesByGroup breaks the sales and refunds of the period down per cashier or
+// per register. Refunds are attributed to the cashier and register of the sale.
+func (repo *transactionRepository) GetSalesByGroup(startDate, endDate time.Time, groupBy string) ([]models.SalesGroup, error) {
+	var groupColumns string
+	switch groupBy {
+	case models.ReportGroupByCashier:
+		groupColumns = "s.cashier_id, COALESCE(u.username, '')"
+	case models.ReportGroupByRegister:
+		groupColumns = "COALESCE(s.register_id, '')"
+	default:
+		return nil, fmt.Errorf("invalid group_by %q", groupBy)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s, SUM(s.sale_count), SUM(s.sales), SUM(s.refund), SUM(s.tax), SUM(s.service)
+		FROM (
+			SELECT t.cashier_id, t.register_id, 1 AS sale_count, t.total_amount AS sales, 0 AS refund,
+				t.tax_amount AS tax, t.service_charge AS service
+			FROM transactions t
+			WHERE t.created_at BETWEEN $1 AND $2
+			UNION ALL
+			SELECT t.cashier_id, t.register_id, 0, 0, r.amount, -r.tax_amount, -r.service_charge
+			FROM refunds r
+			JOIN transactions t ON r.transaction_id = t.id
+			WHERE r.created_at BETWEEN $1 AND $2
+		) s
+		LEFT JOIN users u ON s.cashier_id = u.id
+		GROUP BY %[1]s
+		ORDER BY %[1]s`, groupColumns)
+
+	rows, err := repo.db.Query(query, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]models.SalesGroup, 0)
+	for rows.Next() {
+		var g models.SalesGroup
+		if groupBy == models.ReportGroupByCashier {
+			err = rows.Scan(&g.CashierID, &g.CashierName, &g.TotalTransaksi, &g.TotalSales, &g.TotalRefund, &g.TotalTax, &g.TotalService)
+		} else {
+			err = rows.Scan(&g.RegisterID, &g.TotalTransaksi, &g.TotalSales, &g.TotalRefund, &g.TotalTax, &g.TotalService)
+		}
+		if err != nil {
+			return nil, err
+		}
+		g.TotalRevenue = g.TotalSales - g.TotalRefund
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+func (repo *transactionRepository) GetTransactions(filter models.TransactionFilter) ([]models.Transaction, int, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if filter.StartDate != nil {
+		args = append(args, *filter.StartDate)
+		conditions = append(conditions, fmt.Sprintf("t.created_at >= $%d", len(args)))
+	}
+	if filter.EndDate != nil {
+		args = append(args, *filter.EndDate)
+		conditions = append(conditions, fmt.Sprintf("t.created_at < $%d", len(args)))
+	}
+	if filter.MinAmount != nil {
+		args = append(args, *filter.MinAmount)
+		conditions = append(conditions, fmt.Sprintf("t.total_amount >= $%d", len(args)))
+	}
+	if filter.MaxAmount != nil {
+		args = append(args, *filter.MaxAmount)
+		conditions = append(conditions, fmt.Sprintf("t.total_amount <= $%d", len(args)))
+	}
+	if filter.ProductID > 0 {
+		args = append(args, filter.ProductID)
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM transaction_details td WHERE td.transaction_id = t.id AND td.product_id = $%d)", len(args)))
+	}
+	if filter.CashierID > 0 {
+		args = append(args, filter.CashierID)
+		conditions = append(conditions, fmt.Sprintf("t.cashier_id = $%d", len(args)))
+	}
+	if filter.RegisterID != "" {
+		args = append(args, filter.RegisterID)
+		conditions = append(conditions, fmt.Sprintf("t.register_id = $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM transactions t"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+	query := fmt.Sprintf("%s%s ORDER BY t.created_at DESC, t.id DESC LIMIT $%d OFFSET $%d",
+		transactionSelect, where, len(args)-1, len(args))
+
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	transactions := make([]models.Transaction, 0)
+	ids := make([]int, 0)
+	for rows.Next() {
+		var t models.Transaction
+		if err := scanTransaction(rows, &t); err != nil {
+			return nil, 0, err
+		}
+		transactions = append(transactions, t)
+		ids = append(ids, t.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(ids) == 0 {
+		return transactions, total, nil
+	}
+
+	details, err := repo.getDetails(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	payments, err := repo.getPayments(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	discounts, err := repo.getAppliedDiscounts(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range transactions {
+		transactions[i].Details = details[transactions[i].ID]
+		transactions[i].Discounts = discounts[transactions[i].ID]
+		attachPayments(&transactions[i], payments[transactions[i].ID])
+	}
+
+	return transactions, total, nil
+}
+
+func (repo *transactionRepository) GetTransactionByID(id int) (*models.Transaction, error) {
+	var t models.Transaction
+	err := scanTransaction(repo.db.QueryRow(transactionSelect+" WHERE t.id = $1", id), &t)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("transaction id %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := repo.getDetails([]int{id})
+	if err != nil {
+		return nil, err
+	}
+	t.Details = details[id]
+
+	payments, err := repo.getPayments([]int{id})
+	if err != nil {
+		return nil, err
+	}
+	attachPayments(&t, payments[id])
+
+	discounts, err := repo.getAppliedDiscounts([]int{id})
+	if err != nil {
+		return nil, err
+	}
+	t.Discounts = discounts[id]
+
+	return &t, nil
+}
+
+const transactionSelect = `SELECT t.id, t.gross_amount, t.discount_amount, t.subtotal, t.tax_amount, t.service_charge, t.total_amount, t.tax_inclusive, t.status,
+t.cashier_id, COALESCE(u.username, ''), COALESCE(t.register_id, ''), t.shift_id, t.created_at
+FROM transactions t
+LEFT JOIN users u ON t.cashier_id = u.id`
+
+func scanTransaction(scanner interface{ Scan(...any) error }, t *models.Transaction) error {
+	return scanner.Scan(&t.ID, &t.GrossAmount, &t.DiscountAmount, &t.Subtotal, &t.TaxAmount, &t.ServiceCharge,
+		&t.TotalAmount, &t.TaxInclusive, &t.Status, &t.CashierID, &t.CashierName, &t.RegisterID, &t.ShiftID, &t.CreatedAt)
+}
+
+// getDetails loads the details of the given transactions in one query, keyed by transaction ID.
+func (repo *transactionRepository) getDetails(transactionIDs []int) (map[int][]models.TransactionDetail, error) {
+	query := `
+		SELECT td.id, td.transaction_id, td.product_id, td.product_name, td.category_id, td.category_name, td.unit, td.unit_factor, td.unit_price,
+			td.quantity, td.refunded_quantity, td.gross_subtotal, td.discount_amount, td.subtotal, td.tax_rate, td.tax_amount, td.line_total
+		FROM transaction_details td
+		WHERE td.transaction_id = ANY($1)
+		ORDER BY td.id`
+
+	rows, err := repo.db.Query(query, pq.Array(transactionIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	details := make(map[int][]models.TransactionDetail)
+	for rows.Next() {
+		var d models.TransactionDetail
+		err := rows.Scan(&d.ID, &d.TransactionID, &d.ProductID, &d.ProductName, &d.CategoryID, &d.CategoryName, &d.Unit, &d.UnitFactor, &d.UnitPrice,
+			&d.Quantity, &d.RefundedQuantity,
+			&d.GrossSubtotal, &d.DiscountAmount, &d.Subtotal, &d.TaxRate, &d.TaxAmount, &d.LineTotal)
+		if err != nil {
+			return nil, err
+		}
+		details[d.TransactionID] = append(details[d.TransactionID], d)
+	}
+
+	return details, rows.Err()
+}
+
+// getPayments loads the payment lines of the given transactions, keyed by transaction ID.
+func (repo *transactionRepository) getPayments(transactionIDs []int) (map[int][]models.Payment, error) {
+	query := `
+		SELECT id, transaction_id, method, amount, amount_tendered, change_amount, created_at
+		FROM payments
+		WHERE transaction_id = ANY($1)
+		ORDER BY id`
+
+	rows, err := repo.db.Query(query, pq.Array(transactionIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make(map[int][]models.Payment)
+	for rows.Next() {
+		var p models.Payment
+		err := rows.Scan(&p.ID, &p.TransactionID, &p.Method, &p.Amount, &p.AmountTendered, &p.Change, &p.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		payments[p.TransactionID] = append(payments[p.TransactionID], p)
+	}
+
+	return payments, rows.Err()
+}
+
+// getAppliedDiscounts loads the discounts given on the transactions, keyed by transaction ID.
+func (repo *transactionRepository) getAppliedDiscounts(transactionIDs []int) (map[int][]models.AppliedDiscount, error) {
+	query := `
+		SELECT id, transaction_id, transaction_detail_id, discount_id, name, scope, amount
+		FROM transaction_discounts
+		WHERE transaction_id = ANY($1)
+		ORDER BY id`
+
+	rows, err := repo.db.Query(query, pq.Array(transactionIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discounts := make(map[int][]models.AppliedDiscount, len(transactionIDs))
+	for _, id := range transactionIDs {
+		discounts[id] = make([]models.AppliedDiscount, 0)
+	}
+	for rows.Next() {
+		var d models.AppliedDiscount
+		err := rows.Scan(&d.ID, &d.TransactionID, &d.TransactionDetailID, &d.DiscountID, &d.Name, &d.Scope, &d.Amount)
+		if err != nil {
+			return nil, err
+		}
+		discounts[d.TransactionID] = append(discounts[d.TransactionID], d)
+	}
+
+	return discounts, rows.Err()
+}
+
+func attachPayments(t *models.Transaction, payments []models.Payment) {
+	t.Payments = make([]models.Payment, 0, len(payments))
+	t.TotalPaid, t.Change = 0, 0
+	for _, p := range payments {
+		t.Payments = append(t.Payments, p)
+		t.TotalPaid += p.AmountTendered
+		t.Change += p.Change
+	}
+}
+
+// RefundTransaction refunds the requested detail quantities, or everything that is
+// still refundable when void is true, and puts the stock back in the same DB transaction.
+// Like checkout it is retried on deadlocks and serialization failures.
+func (repo *transactionRepository) RefundTransaction(transactionID int, req models.RefundRequest, void bool) (*models.Refund, error) {
+	var refund *models.Refund
+	err := retryOnConflict(func() error {
+		var err error
+		refund, err = repo.refundTransaction(transactionID, req, void)
+		return err
+	})
+	return refund, err
+}
+
+func (repo *transactionRepository) refundTransaction(transactionID int, req models.RefundRequest, void bool) (*models.Refund, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var serviceCharge int
+	err = tx.QueryRow("SELECT status, service_charge FROM transactions WHERE id = $1 FOR UPDATE", transactionID).Scan(&status, &serviceCharge)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("transaction id %d not found", transactionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status == models.TransactionStatusVoided || status == models.TransactionStatusRefunded {
+		return nil, fmt.Errorf("transaction id %d is already %s", transactionID, status)
+	}
+
+	rows, err := tx.Query("SELECT id, product_id, unit_factor, quantity, refunded_quantity, tax_amount, line_total FROM transaction_details WHERE transaction_id = $1 ORDER BY id FOR UPDATE", transactionID)
+	if err != nil {
+		return nil, err
+	}
+	details := make([]models.TransactionDetail, 0)
+	for rows.Next() {
+		var d models.TransactionDetail
+		if err := rows.Scan(&d.ID, &d.ProductID, &d.UnitFactor, &d.Quantity, &d.RefundedQuantity, &d.TaxAmount, &d.LineTotal); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		details = append(details, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Quantity to refund per detail ID
+	requested := make(map[int]int)
+	if void {
+		for _, d := range details {
+			requested[d.ID] = d.Quantity - d.RefundedQuantity
+		}
+	} else {
+		known := make(map[int]bool, len(details))
+		for _, d := range details {
+			known[d.ID] = true
+		}
+		for _, item := range req.Items {
+			if !known[item.DetailID] {
+				return nil, fmt.Errorf("transaction detail id %d not found in transaction %d", item.DetailID, transactionID)
+			}
+			requested[item.DetailID] += item.Quantity
+		}
+	}
+
+	refund := models.Refund{
+		TransactionID: transactionID,
+		Reason:        req.Reason,
+		CreatedAt:     models.GetCurrentTime(),
+		Items:         make([]models.RefundItem, 0),
+	}
+
+	// A refund issued by someone with an open shift is paid out of that drawer
+	if req.UserID > 0 {
+		refund.UserID = &req.UserID
+		shift, err := openShift(tx, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if shift != nil {
+			refund.ShiftID = &shift.ID
+		}
+	}
+	fullyRefunded := true
+	linesTotal, linesBefore, linesAfter := 0, 0, 0
+
+	for i := range details {
+		d := &details[i]
+		qty := requested[d.ID]
+		remaining := d.Quantity - d.RefundedQuantity
+		if qty > remaining {
+			return nil, fmt.Errorf("refund quantity for detail id %d exceeds refundable quantity %d", d.ID, remaining)
+		}
+		linesTotal += d.LineTotal
+		linesBefore += refundShare(d.LineTotal, *d, 0)
+		if qty > 0 {
+			refund.Items = append(refund.Items, models.RefundItem{
+				TransactionDetailID: d.ID,
+				ProductID:           d.ProductID,
+				Quantity:            qty,
+				Amount:              refundShare(d.LineTotal, *d, qty) - refundShare(d.LineTotal, *d, 0),
+			})
+			refund.Amount += refund.Items[len(refund.Items)-1].Amount
+			refund.TaxAmount += refundShare(d.TaxAmount, *d, qty) - refundShare(d.TaxAmount, *d, 0)
+			d.RefundedQuantity += qty
+		}
+		linesAfter += refundShare(d.LineTotal, *d, 0)
+		if d.RefundedQuantity < d.Quantity {
+			fullyRefunded = false
+		}
+	}
+
+	if len(refund.Items) == 0 {
+		return nil, fmt.Errorf("nothing left to refund for transaction id %d", transactionID)
+	}
+
+	// The service charge goes back in proportion to the line totals refunded so far,
+	// so once every line is refunded the whole total_amount has been paid back
+	if linesTotal > 0 {
+		refund.ServiceCharge = serviceCharge*linesAfter/linesTotal - serviceCharge*linesBefore/linesTotal
+	}
+	refund.Amount += refund.ServiceCharge
+
+	err = tx.QueryRow("INSERT INTO refunds (transaction_id, reason, amount, tax_amount, service_charge, user_id, shift_id, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		transactionID, refund.Reason, refund.Amount, refund.TaxAmount, refund.ServiceCharge, refund.UserID, refund.ShiftID, refund.CreatedAt).Scan(&refund.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refunded quantities are in the unit sold; stock goes back in base units
+	unitFactor := make(map[int]int, len(details))
+	for _, d := range details {
+		unitFactor[d.ID] = d.UnitFactor
+	}
+
+	// Restock in product ID order, the same order checkouts lock products in
+	sort.SliceStable(refund.Items, func(i, j int) bool { return refund.Items[i].ProductID < refund.Items[j].ProductID })
+	for i := range refund.Items {
+		item := &refund.Items[i]
+		item.RefundID = refund.ID
+		baseQty := item.Quantity * unitFactor[item.TransactionDetailID]
+
+		err = tx.QueryRow("INSERT INTO refund_items (refund_id, transaction_detail_id, product_id, quantity, amount) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			refund.ID, item.TransactionDetailID, item.ProductID, item.Quantity, item.Amount).Scan(&item.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec("UPDATE transaction_details SET refunded_quantity = refunded_quantity + $1 WHERE id = $2", item.Quantity, item.TransactionDetailID)
+		if err != nil {
+			return nil, err
+		}
+
+		// return the goods to stock
+		var newStock int
+		err = tx.QueryRow("UPDATE products SET stock = stock + $1 WHERE id = $2 RETURNING stock", baseQty, item.ProductID).Scan(&newStock)
+		if err != nil {
+			return nil, err
+		}
+
+		err = recordStockMovement(tx, &models.StockMovement{
+			ProductID:   item.ProductID,
+			Type:        models.StockMovementRefund,
+			Quantity:    baseQty,
+			StockAfter:  newStock,
+			Reason:      refund.Reason,
+			ReferenceID: &refund.ID,
+			UserID:      refund.UserID,
+			CreatedAt:   refund.CreatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newStatus := models.TransactionStatusPartiallyRefunded
+	if void {
+		newStatus = models.TransactionStatusVoided
+	} else if fullyRefunded {
+		newStatus = models.TransactionStatusRefunded
+	}
+
+	_, err = tx.Exec("UPDATE transactions SET status = $1 WHERE id = $2", newStatus, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// refundShare returns the part of amount, one of d's line amounts, that has been paid
+// back once qty more units of d are refunded. Refunds take the difference of two
+// cumulative shares so rounding never returns more than amount in total.
+func refundShare(amount int, d models.TransactionDetail, qty int) int {
+	return amount * (d.RefundedQuantity + qty) / d.Quantity
+}
@@ -3,12 +3,20 @@ package repositories
 import (
 	"kasir-api/models"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 )
 
+var transactionColumns = []string{"id", "gross_amount", "discount_amount", "subtotal", "tax_amount", "service_charge", "total_amount", "tax_inclusive", "status", "cashier_id", "username", "register_id", "shift_id", "created_at"}
+
+var checkoutProductColumns = []string{"name", "price", "cost_price", "stock", "category_id", "category_name", "tax_exempt", "tax_rate", "reorder_point", "reorder_qty", "archived",
+	"base_unit", "unit_factor", "unit_price"}
+
+var detailColumns = []string{"id", "transaction_id", "product_id", "product_name", "category_id", "category_name", "unit", "unit_factor", "unit_price", "quantity", "refunded_quantity", "gross_subtotal", "discount_amount", "subtotal", "tax_rate", "tax_amount", "line_total"}
+
 func TestCreateTransaction_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -16,45 +24,290 @@ func TestCreateTransaction_Success(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := NewTransactionRepository(db)
+	repo := NewTransactionRepository(db, models.TaxConfig{Rate: 11})
 
-	items := []models.CheckoutItem{
-		{ProductID: 1, Quantity: 2},
+	req := models.CheckoutRequest{
+		Items: []models.CheckoutItem{
+			{ProductID: 1, Quantity: 2},
+		},
+		Payments: []models.PaymentRequest{
+			{Method: models.PaymentMethodCash, AmountTendered: 5000},
+		},
+		RegisterID:  "KASIR-1",
+		CashierID:   3,
+		CashierName: "siti",
 	}
 
 	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Mock open shift of the cashier
+	mock.ExpectQuery("SELECT id, register_id FROM shifts WHERE cashier_id = \\$1 AND status = \\$2 FOR SHARE").
+		WithArgs(3, models.ShiftStatusOpen).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "register_id"}).AddRow(5, "KASIR-1"))
 
 	// Mock product query
-	rows := sqlmock.NewRows([]string{"name", "price", "stock"}).
-		AddRow("Test Product", 1000, 10)
-	mock.ExpectQuery("SELECT name, price, stock FROM products WHERE id = \\$1").
-		WithArgs(1).
+	rows := sqlmock.NewRows(checkoutProductColumns).
+		AddRow("Test Product", 1000, 700, 10, 1, "Makanan", false, nil, 9, 24, false, "pcs", nil, nil)
+	mock.ExpectQuery("SELECT p.name, p.price, p.cost_price, p.stock, COALESCE\\(p.category_id, 0\\), COALESCE\\(c.name, ''\\), p.tax_exempt, c.tax_rate, p.reorder_point, p.reorder_qty, p.archived_at IS NOT NULL, p.base_unit, u.factor, u.price FROM products p").
+		WithArgs(1, "").
 		WillReturnRows(rows)
 
 	// Mock update stock
-	mock.ExpectExec("UPDATE products SET stock = stock - \\$1 WHERE id = \\$2 AND stock >= \\$1").
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1 WHERE id = \\$2 AND stock >= \\$1 RETURNING stock").
 		WithArgs(2, 1).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(8))
+
+	// Mock stock reserved for other carts
+	mock.ExpectQuery("FROM stock_reservations WHERE product_id = \\$1 AND cart_id <> \\$2").
+		WithArgs(1, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(3))
+
+	// Mock active discounts: none
+	mock.ExpectQuery("FROM discounts").
+		WithArgs(sqlmock.AnyArg(), "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "type", "value", "scope", "product_id", "category_id", "promo_code", "min_purchase", "starts_at", "ends_at", "active"}))
 
 	// Mock insert transaction
 	mock.ExpectQuery("INSERT INTO transactions").
-		WithArgs(2000, sqlmock.AnyArg()).
+		WithArgs(2000, 0, 2000, 220, 0, 2220, false, 3, "KASIR-1", 5, sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	// Mock insert transaction details
 	mock.ExpectQuery("INSERT INTO transaction_details").
-		WithArgs(1, 1, 2, 2000).
+		WithArgs(1, 1, "Test Product", 1, "Makanan", "pcs", 1, 1000, 2, 2000, 0, 2000, 11.0, 220, 2220, 700).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	// Mock sale entry in the stock ledger
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, models.StockMovementSale, -2, 8, nil, 1, 3, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	// Mock insert payment
+	mock.ExpectQuery("INSERT INTO payments").
+		WithArgs(1, "cash", 2220, 5000, 2780, sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	mock.ExpectCommit()
 
-	tx, err := repo.CreateTransaction(items, false)
+	tx, err := repo.CreateTransaction(req, false)
 	if err != nil {
-		t.Errorf("error was not expected while creating transaction: %s", err)
+		t.Fatalf("error was not expected while creating transaction: %s", err)
 	}
 
 	if tx == nil {
-		t.Errorf("expected transaction, got nil")
+		t.Fatalf("expected transaction, got nil")
+	}
+	if tx.TotalAmount != 2220 || tx.TaxAmount != 220 {
+		t.Errorf("expected total 2220 with tax 220, got total %d tax %d", tx.TotalAmount, tx.TaxAmount)
+	}
+	if d := tx.Details[0]; d.ProductName != "Test Product" || d.UnitPrice != 1000 || d.CategoryID == nil || *d.CategoryID != 1 || d.CategoryName != "Makanan" {
+		t.Errorf("expected the product snapshot on the detail, got %+v", d)
+	}
+	if tx.Change != 2780 || len(tx.Payments) != 1 {
+		t.Errorf("expected one payment with change 2780, got change %d and %+v", tx.Change, tx.Payments)
+	}
+	if tx.CashierID == nil || *tx.CashierID != 3 || tx.RegisterID != "KASIR-1" {
+		t.Errorf("expected cashier 3 on register KASIR-1, got %v and %q", tx.CashierID, tx.RegisterID)
+	}
+	// Stock went from 10 to 8, crossing the reorder point of 9
+	if len(tx.LowStock) != 1 || tx.LowStock[0].Stock != 8 || tx.LowStock[0].TransactionID != 1 {
+		t.Errorf("expected one low stock event at stock 8, got %+v", tx.LowStock)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateTransaction_SellsPackInBaseUnits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+
+	req := models.CheckoutRequest{
+		Items:     []models.CheckoutItem{{ProductID: 1, Unit: "dus", Quantity: 1}},
+		Payments:  []models.PaymentRequest{{Method: models.PaymentMethodQRIS, AmountTendered: 110000}},
+		CashierID: 3,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM shifts").
+		WithArgs(3, models.ShiftStatusOpen).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "register_id"}).AddRow(5, "KASIR-1"))
+
+	// A dus is 40 pcs at its own price
+	mock.ExpectQuery("LEFT JOIN product_units u ON u.product_id = p.id AND u.unit = \\$2").
+		WithArgs(1, "dus").
+		WillReturnRows(sqlmock.NewRows(checkoutProductColumns).AddRow("Indomie", 3500, 2500, 100, 1, "Makanan", false, nil, nil, 0, false, "pcs", 40, 110000))
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1").
+		WithArgs(40, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(60))
+	mock.ExpectQuery("FROM stock_reservations").
+		WithArgs(1, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(0))
+	mock.ExpectQuery("FROM discounts").
+		WithArgs(sqlmock.AnyArg(), "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "type", "value", "scope", "product_id", "category_id", "promo_code", "min_purchase", "starts_at", "ends_at", "active"}))
+	mock.ExpectQuery("INSERT INTO transactions").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO transaction_details").
+		WithArgs(1, 1, "Indomie", 1, "Makanan", "dus", 40, 110000, 1, 110000, 0, 110000, 0.0, 0, 110000, 100000).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, models.StockMovementSale, -40, 60, nil, 1, 3, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO payments").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	tx, err := repo.CreateTransaction(req, false)
+	if err != nil {
+		t.Fatalf("error was not expected while creating transaction: %s", err)
+	}
+	if tx.TotalAmount != 110000 || tx.Details[0].Unit != "dus" || tx.Details[0].UnitFactor != 40 {
+		t.Errorf("expected one dus for 110000, got total %d and %+v", tx.TotalAmount, tx.Details[0])
+	}
+
+	// A unit the product is not sold in is rejected
+	req.Items[0].Unit = "pak"
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM shifts").
+		WithArgs(3, models.ShiftStatusOpen).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "register_id"}).AddRow(5, "KASIR-1"))
+	mock.ExpectQuery("FROM products p").
+		WithArgs(1, "pak").
+		WillReturnRows(sqlmock.NewRows(checkoutProductColumns).AddRow("Indomie", 3500, 2500, 60, 1, "Makanan", false, nil, nil, 0, false, "pcs", nil, nil))
+	mock.ExpectRollback()
+
+	_, err = repo.CreateTransaction(req, false)
+	if err == nil || !strings.Contains(err.Error(), "invalid unit") {
+		t.Fatalf("expected invalid unit error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateTransaction_RequiresOpenShift(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM shifts").
+		WithArgs(3, models.ShiftStatusOpen).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "register_id"}))
+	mock.ExpectRollback()
+
+	_, err = repo.CreateTransaction(models.CheckoutRequest{
+		Items:     []models.CheckoutItem{{ProductID: 1, Quantity: 1}},
+		Payments:  []models.PaymentRequest{{Method: models.PaymentMethodCash, AmountTendered: 5000}},
+		CashierID: 3,
+	}, false)
+	if err == nil || !strings.Contains(err.Error(), "no open shift") {
+		t.Fatalf("expected no open shift error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateTransaction_ChecksOutCartInSameTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM shifts").
+		WithArgs(3, models.ShiftStatusOpen).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "register_id"}).AddRow(5, "KASIR-1"))
+	// The cart is locked and sold as it is now, not as the caller last saw it
+	mock.ExpectQuery("SELECT status, promo_code FROM carts WHERE id = \\$1 FOR UPDATE").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "promo_code"}).AddRow(models.CartStatusParked, ""))
+	mock.ExpectQuery("SELECT product_id, unit, quantity FROM cart_items WHERE cart_id = \\$1").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "unit", "quantity"}).AddRow(1, "", 3))
+	mock.ExpectQuery("FROM products p").
+		WithArgs(1, "").
+		WillReturnRows(sqlmock.NewRows(checkoutProductColumns).
+			AddRow("Indomie", 3000, 2000, 10, 1, "Makanan", false, nil, nil, 0, false, "pcs", nil, nil))
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1").
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(7))
+	mock.ExpectQuery("FROM stock_reservations WHERE product_id = \\$1 AND cart_id <> \\$2").
+		WithArgs(1, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(0))
+	mock.ExpectQuery("FROM discounts").
+		WithArgs(sqlmock.AnyArg(), "").
+		WillReturnRows(sqlmock.NewRows(discountRows))
+	mock.ExpectQuery("INSERT INTO transactions").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery("INSERT INTO transaction_details").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO payments").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("DELETE FROM stock_reservations WHERE cart_id = \\$1").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE carts SET status = \\$1, transaction_id = \\$2").
+		WithArgs(models.CartStatusCheckedOut, 9, sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	transaction, err := repo.CreateTransaction(models.CheckoutRequest{
+		Items:     []models.CheckoutItem{{ProductID: 1, Quantity: 1}},
+		Payments:  []models.PaymentRequest{{Method: models.PaymentMethodQRIS, AmountTendered: 9000}},
+		CashierID: 3,
+		CartID:    4,
+	}, false)
+	if err != nil {
+		t.Fatalf("error was not expected while checking out cart: %s", err)
+	}
+	if transaction.TotalAmount != 9000 || transaction.Details[0].Quantity != 3 {
+		t.Errorf("expected the 3 items of the cart for 9000, got %+v", transaction)
+	}
+
+	// A second checkout of the same cart finds it closed
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM shifts").
+		WithArgs(3, models.ShiftStatusOpen).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "register_id"}).AddRow(5, "KASIR-1"))
+	mock.ExpectQuery("FROM carts WHERE id = \\$1 FOR UPDATE").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "promo_code"}).AddRow(models.CartStatusCheckedOut, ""))
+	mock.ExpectRollback()
+
+	_, err = repo.CreateTransaction(models.CheckoutRequest{
+		Items:     []models.CheckoutItem{{ProductID: 1, Quantity: 3}},
+		Payments:  []models.PaymentRequest{{Method: models.PaymentMethodQRIS, AmountTendered: 9000}},
+		CashierID: 3,
+		CartID:    4,
+	}, false)
+	if err == nil || !strings.Contains(err.Error(), "already checked_out") {
+		t.Fatalf("expected cart already checked out, got %v", err)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -69,29 +322,413 @@ func TestGetSalesSummary_Success(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := NewTransactionRepository(db)
+	repo := NewTransactionRepository(db, models.TaxConfig{})
 	now := time.Now()
 
 	// Mock Revenue Query
-	mock.ExpectQuery("SELECT COUNT\\(\\*\\), COALESCE\\(SUM\\(total_amount\\), 0\\) FROM transactions").
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*), COALESCE(SUM(gross_amount), 0), COALESCE(SUM(discount_amount), 0), COALESCE(SUM(subtotal), 0)")).
+		WithArgs(now, now).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "gross", "discount", "subtotal", "tax", "service", "revenue"}).
+			AddRow(5, 55000, 5000, 45045, 4955, 0, 50000))
+
+	// Mock Refund Query
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\), COALESCE\\(SUM\\(tax_amount\\), 0\\), COALESCE\\(SUM\\(service_charge\\), 0\\) FROM refunds").
+		WithArgs(now, now).
+		WillReturnRows(sqlmock.NewRows([]string{"refund", "tax", "service"}).AddRow(5000, 450, 0))
+
+	// Mock Net Sales and COGS Query
+	mock.ExpectQuery("FROM transaction_details td JOIN transactions t ON td.transaction_id = t.id WHERE t.created_at BETWEEN \\$1 AND \\$2$").
 		WithArgs(now, now).
-		WillReturnRows(sqlmock.NewRows([]string{"count", "revenue"}).AddRow(5, 50000))
+		WillReturnRows(sqlmock.NewRows([]string{"quantity", "net_sales", "cogs"}).AddRow(12, 40000, 30000))
 
 	// Mock Best Seller Query
 	// Note: We use regexp for complex query matching
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT p.name, COALESCE(SUM(td.quantity), 0) as total_qty FROM transaction_details`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT (ARRAY_AGG(td.product_name ORDER BY td.id DESC))[1], COALESCE(SUM((td.quantity - td.refunded_quantity) * td.unit_factor), 0) as total_qty FROM transaction_details td JOIN transactions t ON td.transaction_id = t.id WHERE t.created_at BETWEEN $1 AND $2 GROUP BY td.product_id`)).
 		WithArgs(now, now).
 		WillReturnRows(sqlmock.NewRows([]string{"name", "total_qty"}).AddRow("Best Product", 10))
 
+	// Mock Payment Breakdown Query
+	mock.ExpectQuery("FROM payments p").
+		WithArgs(now, now).
+		WillReturnRows(sqlmock.NewRows([]string{"method", "count", "total"}).
+			AddRow("cash", 3, 30000).
+			AddRow("qris", 2, 20000))
+
 	summary, err := repo.GetSalesSummary(now, now)
 	if err != nil {
 		t.Errorf("error was not expected while getting summary: %s", err)
 	}
 
-	if summary.TotalRevenue != 50000 {
-		t.Errorf("expected revenue 50000, got %d", summary.TotalRevenue)
+	if summary.TotalRevenue != 45000 {
+		t.Errorf("expected net revenue 45000, got %d", summary.TotalRevenue)
+	}
+	if summary.TotalTax != 4505 {
+		t.Errorf("expected tax net of refunds 4505, got %d", summary.TotalTax)
+	}
+	if summary.GrossProfit != 10000 || summary.GrossMargin != 25 {
+		t.Errorf("expected gross profit 10000 at 25%%, got %d at %v", summary.GrossProfit, summary.GrossMargin)
 	}
 	if summary.ProdukTerlaris.Nama != "Best Product" {
 		t.Errorf("expected best seller 'Best Product', got '%s'", summary.ProdukTerlaris.Nama)
 	}
+	if len(summary.PaymentMethods) != 2 {
+		t.Errorf("expected 2 payment methods, got %+v", summary.PaymentMethods)
+	}
+}
+
+func TestGetMargins_Product(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY td.product_id")).
+		WithArgs(now, now).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "name", "quantity", "net_sales", "cogs"}).
+			AddRow(3, "Kecap", 4, 48000, 36000).
+			AddRow(1, "Indomie", 10, 35000, 28000))
+
+	margins, err := repo.GetMargins(now, now, models.ReportGroupByProduct)
+	if err != nil {
+		t.Fatalf("error was not expected while getting margins: %s", err)
+	}
+
+	if len(margins) != 2 || *margins[0].ProductID != 3 || margins[0].GrossProfit != 12000 || margins[0].GrossMargin != 25 {
+		t.Errorf("expected Kecap first with profit 12000 at 25%%, got %+v", margins)
+	}
+	if margins[1].GrossMargin != 20 {
+		t.Errorf("expected Indomie margin 20%%, got %v", margins[1].GrossMargin)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetSalesByGroup_Cashier(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY s.cashier_id, COALESCE(u.username, '')")).
+		WithArgs(now, now).
+		WillReturnRows(sqlmock.NewRows([]string{"cashier_id", "username", "count", "sales", "refund", "tax", "service"}).
+			AddRow(1, "admin", 2, 20000, 0, 1982, 0).
+			AddRow(3, "siti", 4, 30000, 5000, 2477, 0))
+
+	groups, err := repo.GetSalesByGroup(now, now, models.ReportGroupByCashier)
+	if err != nil {
+		t.Fatalf("error was not expected while grouping sales: %s", err)
+	}
+
+	if len(groups) != 2 || groups[1].CashierName != "siti" || groups[1].TotalRevenue != 25000 || groups[1].TotalTax != 2477 {
+		t.Errorf("expected siti with net revenue 25000 and tax 2477, got %+v", groups)
+	}
+
+	if _, err := repo.GetSalesByGroup(now, now, "product"); err == nil {
+		t.Error("expected error for unknown group_by")
+	}
+}
+
+func TestGetTransactionByID_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+	now := time.Now()
+
+	mock.ExpectQuery("FROM transactions t LEFT JOIN users u ON t.cashier_id = u.id WHERE t.id = \\$1").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(transactionColumns).AddRow(7, 7000, 0, 7000, 0, 0, 7000, false, "completed", 3, "siti", "KASIR-1", 5, now))
+
+	mock.ExpectQuery("FROM transaction_details td").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(detailColumns).
+			AddRow(1, 7, 1, "Indomie", 1, "Makanan", "pcs", 1, 3500, 2, 0, 7000, 0, 7000, 0, 0, 7000))
+
+	mock.ExpectQuery("FROM payments").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "method", "amount", "amount_tendered", "change_amount", "created_at"}).
+			AddRow(1, 7, "qris", 7000, 7000, 0, now))
+
+	mock.ExpectQuery("FROM transaction_discounts").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "transaction_detail_id", "discount_id", "name", "scope", "amount"}))
+
+	tx, err := repo.GetTransactionByID(7)
+	if err != nil {
+		t.Fatalf("error was not expected while getting transaction: %s", err)
+	}
+
+	if len(tx.Details) != 1 || tx.Details[0].ProductName != "Indomie" {
+		t.Errorf("expected one detail for Indomie, got %+v", tx.Details)
+	}
+	if tx.CashierName != "siti" || tx.RegisterID != "KASIR-1" {
+		t.Errorf("expected cashier siti on register KASIR-1, got %q and %q", tx.CashierName, tx.RegisterID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetTransactions_FilterByProduct(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+	now := time.Now()
+	minAmount := 1000
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM transactions t WHERE t.total_amount >= $1 AND EXISTS")).
+		WithArgs(1000, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	mock.ExpectQuery(regexp.QuoteMeta("ORDER BY t.created_at DESC, t.id DESC LIMIT $3 OFFSET $4")).
+		WithArgs(1000, 3, 10, 10).
+		WillReturnRows(sqlmock.NewRows(transactionColumns).AddRow(9, 12000, 0, 12000, 0, 0, 12000, false, "completed", nil, "", "", nil, now))
+
+	mock.ExpectQuery("FROM transaction_details td").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(detailColumns).
+			AddRow(4, 9, 3, "Kecap", nil, "", "btl", 1, 12000, 1, 0, 12000, 0, 12000, 0, 0, 12000))
+
+	mock.ExpectQuery("FROM payments").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "method", "amount", "amount_tendered", "change_amount", "created_at"}))
+
+	mock.ExpectQuery("FROM transaction_discounts").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "transaction_detail_id", "discount_id", "name", "scope", "amount"}))
+
+	transactions, total, err := repo.GetTransactions(models.TransactionFilter{
+		ProductID: 3,
+		MinAmount: &minAmount,
+		Page:      2,
+		Limit:     10,
+	})
+	if err != nil {
+		t.Fatalf("error was not expected while listing transactions: %s", err)
+	}
+
+	if total != 1 || len(transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got total=%d len=%d", total, len(transactions))
+	}
+	if len(transactions[0].Details) != 1 {
+		t.Errorf("expected details to be attached, got %+v", transactions[0].Details)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRefundTransaction_PartialRestoresStock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, service_charge FROM transactions WHERE id = \\$1 FOR UPDATE").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "service_charge"}).AddRow("completed", 1300))
+	mock.ExpectQuery("SELECT id, product_id, unit_factor, quantity, refunded_quantity, tax_amount, line_total FROM transaction_details").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "unit_factor", "quantity", "refunded_quantity", "tax_amount", "line_total"}).
+			AddRow(10, 1, 1, 3, 0, 991, 10000).
+			AddRow(11, 2, 1, 1, 0, 297, 3000))
+	mock.ExpectQuery("FROM shifts").
+		WithArgs(2, models.ShiftStatusOpen).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "register_id"}).AddRow(4, "KASIR-1"))
+	mock.ExpectQuery("INSERT INTO refunds").
+		WithArgs(5, "rusak", 3666, 330, 333, 2, 4, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO refund_items").
+		WithArgs(1, 10, 1, 1, 3333).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE transaction_details SET refunded_quantity").
+		WithArgs(1, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2 RETURNING stock").
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(6))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs(1, models.StockMovementRefund, 1, 6, "rusak", 1, 2, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("UPDATE transactions SET status").
+		WithArgs(models.TransactionStatusPartiallyRefunded, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	refund, err := repo.RefundTransaction(5, models.RefundRequest{
+		Reason: "rusak",
+		Items:  []models.RefundItemRequest{{DetailID: 10, Quantity: 1}},
+		UserID: 2,
+	}, false)
+	if err != nil {
+		t.Fatalf("error was not expected while refunding: %s", err)
+	}
+
+	// a third of line 10 plus its share of the service charge
+	if refund.Amount != 3666 || refund.ServiceCharge != 333 || refund.Items[0].Amount != 3333 {
+		t.Errorf("expected refund of 3333 plus 333 service, got %+v", refund)
+	}
+	if refund.ShiftID == nil || *refund.ShiftID != 4 {
+		t.Errorf("expected refund to be paid from shift 4, got %v", refund.ShiftID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRefundTransaction_VoidRefundsRemainingTotal(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+
+	// total_amount 14300 = lines 13000 + service 1300; 3333 + 333 service was refunded before
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, service_charge FROM transactions WHERE id = \\$1 FOR UPDATE").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "service_charge"}).AddRow("partially_refunded", 1300))
+	mock.ExpectQuery("SELECT id, product_id, unit_factor, quantity, refunded_quantity, tax_amount, line_total FROM transaction_details").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "unit_factor", "quantity", "refunded_quantity", "tax_amount", "line_total"}).
+			AddRow(10, 1, 1, 3, 1, 991, 10000).
+			AddRow(11, 2, 1, 1, 0, 297, 3000))
+	mock.ExpectQuery("INSERT INTO refunds").
+		WithArgs(5, "batal", 10634, 958, 967, nil, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	for _, item := range []struct{ detailID, productID, qty, amount, stock int }{
+		{10, 1, 2, 6667, 7},
+		{11, 2, 1, 3000, 4},
+	} {
+		mock.ExpectQuery("INSERT INTO refund_items").
+			WithArgs(2, item.detailID, item.productID, item.qty, item.amount).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(item.detailID))
+		mock.ExpectExec("UPDATE transaction_details SET refunded_quantity").
+			WithArgs(item.qty, item.detailID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1 WHERE id = \\$2 RETURNING stock").
+			WithArgs(item.qty, item.productID).
+			WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(item.stock))
+		mock.ExpectQuery("INSERT INTO stock_movements").
+			WithArgs(item.productID, models.StockMovementRefund, item.qty, item.stock, "batal", 2, nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(item.detailID))
+	}
+	mock.ExpectExec("UPDATE transactions SET status").
+		WithArgs(models.TransactionStatusVoided, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	refund, err := repo.RefundTransaction(5, models.RefundRequest{Reason: "batal"}, true)
+	if err != nil {
+		t.Fatalf("error was not expected while voiding: %s", err)
+	}
+
+	// together with the earlier refund of 3666 the whole total_amount is paid back
+	if refund.Amount+3666 != 14300 {
+		t.Errorf("expected void to refund the remaining 10634, got %d", refund.Amount)
+	}
+	if refund.ServiceCharge+333 != 1300 || refund.TaxAmount+330 != 991+297 {
+		t.Errorf("expected the rest of service and tax back, got service %d tax %d", refund.ServiceCharge, refund.TaxAmount)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRefundTransaction_RejectsOverRefund(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, models.TaxConfig{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, service_charge FROM transactions").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "service_charge"}).AddRow("partially_refunded", 0))
+	mock.ExpectQuery("SELECT id, product_id, unit_factor, quantity, refunded_quantity, tax_amount, line_total FROM transaction_details").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "unit_factor", "quantity", "refunded_quantity", "tax_amount", "line_total"}).
+			AddRow(10, 1, 1, 3, 2, 0, 10000))
+	mock.ExpectRollback()
+
+	_, err = repo.RefundTransaction(5, models.RefundRequest{
+		Reason: "retur",
+		Items:  []models.RefundItemRequest{{DetailID: 10, Quantity: 2}},
+	}, false)
+	if err == nil {
+		t.Fatal("expected error when refunding more than remaining quantity")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSettlePayments(t *testing.T) {
+	tests := []struct {
+		name       string
+		payments   []models.PaymentRequest
+		wantChange int
+		wantErr    bool
+	}{
+		{"cash with change", []models.PaymentRequest{{Method: "cash", AmountTendered: 20000}}, 5000, false},
+		{"exact qris", []models.PaymentRequest{{Method: "qris", AmountTendered: 15000}}, 0, false},
+		{"cash and qris split", []models.PaymentRequest{{Method: "qris", AmountTendered: 10000}, {Method: "cash", AmountTendered: 10000}}, 5000, false},
+		{"underpaid split", []models.PaymentRequest{{Method: "debit", AmountTendered: 5000}, {Method: "cash", AmountTendered: 5000}}, 0, true},
+		{"overpaid debit", []models.PaymentRequest{{Method: "debit", AmountTendered: 20000}}, 0, true},
+		{"no payment", nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments, change, err := settlePayments(15000, tt.payments)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if change != tt.wantChange {
+				t.Errorf("expected change %d, got %d", tt.wantChange, change)
+			}
+			applied := 0
+			for _, p := range payments {
+				applied += p.Amount
+			}
+			if applied != 15000 {
+				t.Errorf("expected applied amounts to equal total 15000, got %d", applied)
+			}
+		})
+	}
 }
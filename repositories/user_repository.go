@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"kasir-api/models"
+)
+
+type UserRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (repo *UserRepository) GetAll() ([]models.User, error) {
+	rows, err := repo.db.Query("SELECT id, username, role, created_at FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+func (repo *UserRepository) GetByUsername(username string) (*models.User, error) {
+	query := "SELECT id, username, role, password_hash, created_at FROM users WHERE username = $1"
+
+	var u models.User
+	err := repo.db.QueryRow(query, username).Scan(&u.ID, &u.Username, &u.Role, &u.PasswordHash, &u.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("user tidak ditemukan")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (repo *UserRepository) Create(user *models.User) error {
+	query := "INSERT INTO users (username, password_hash, role, created_at) VALUES ($1, $2, $3, $4) RETURNING id"
+	return repo.db.QueryRow(query, user.Username, user.PasswordHash, user.Role, user.CreatedAt).Scan(&user.ID)
+}
+
+func (repo *UserRepository) Count() (int, error) {
+	var count int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"kasir-api/auth"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"strings"
+	"time"
+)
+
+type AuthService struct {
+	repo     *repositories.UserRepository
+	secret   []byte
+	tokenTTL time.Duration
+}
+
+func NewAuthService(repo *repositories.UserRepository, secret []byte, tokenTTL time.Duration) *AuthService {
+	return &AuthService{repo: repo, secret: secret, tokenTTL: tokenTTL}
+}
+
+// Login checks the credentials and issues a signed access token.
+func (s *AuthService) Login(req models.LoginRequest) (*models.LoginResponse, error) {
+	user, err := s.repo.GetByUsername(req.Username)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak ditemukan") {
+			return nil, fmt.Errorf("invalid username or password")
+		}
+		return nil, err
+	}
+	if !auth.CheckPassword(user.PasswordHash, req.Password) {
+		return nil, fmt.Errorf("invalid username or password")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.tokenTTL)
+	token, err := auth.SignToken(auth.Claims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}, s.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{Token: token, ExpiresAt: expiresAt, User: *user}, nil
+}
+
+func (s *AuthService) GetAllUsers() ([]models.User, error) {
+	return s.repo.GetAll()
+}
+
+func (s *AuthService) CreateUser(req models.CreateUserRequest) (*models.User, error) {
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+	if len(req.Password) < 8 {
+		return nil, fmt.Errorf("password must be at least 8 characters")
+	}
+	if req.Role != models.RoleAdmin && req.Role != models.RoleCashier {
+		return nil, fmt.Errorf("invalid role %q (admin, cashier)", req.Role)
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Username:     req.Username,
+		Role:         req.Role,
+		PasswordHash: hash,
+		CreatedAt:    models.GetCurrentTime(),
+	}
+	if err := s.repo.Create(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// EnsureAdmin creates the first admin account when the users table is still empty.
+// It returns false when users already exist.
+func (s *AuthService) EnsureAdmin(username, password string) (bool, error) {
+	count, err := s.repo.Count()
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	_, err = s.CreateUser(models.CreateUserRequest{Username: username, Password: password, Role: models.RoleAdmin})
+	return err == nil, err
+}
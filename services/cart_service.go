@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"log"
+	"strings"
+	"time"
+)
+
+// CartService manages held carts. A parked cart reserves the stock of its items
+// for reservationTTL.
+type CartService struct {
+	repo           *repositories.CartRepository
+	transactions   *TransactionService
+	reservationTTL time.Duration
+}
+
+func NewCartService(repo *repositories.CartRepository, transactions *TransactionService, reservationTTL time.Duration) *CartService {
+	return &CartService{repo: repo, transactions: transactions, reservationTTL: reservationTTL}
+}
+
+func (s *CartService) GetAll(status string) ([]models.Cart, error) {
+	switch status {
+	case "", models.CartStatusOpen, models.CartStatusParked, models.CartStatusCheckedOut, models.CartStatusCancelled:
+	default:
+		return nil, fmt.Errorf("invalid status %q (open, parked, checked_out, cancelled)", status)
+	}
+	return s.repo.GetAll(status)
+}
+
+func (s *CartService) GetByID(id int) (*models.Cart, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *CartService) Create(req models.CartRequest, userID int) (*models.Cart, error) {
+	cart := models.Cart{
+		Name:      strings.TrimSpace(req.Name),
+		PromoCode: strings.ToUpper(strings.TrimSpace(req.PromoCode)),
+		CreatedAt: models.GetCurrentTime(),
+	}
+	if userID > 0 {
+		cart.CreatedBy = &userID
+	}
+	if err := s.repo.Create(&cart); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(cart.ID)
+}
+
+func (s *CartService) Update(id int, req models.CartUpdateRequest) (*models.Cart, error) {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		req.Name = &name
+	}
+	if req.PromoCode != nil {
+		code := strings.ToUpper(strings.TrimSpace(*req.PromoCode))
+		req.PromoCode = &code
+	}
+	if err := s.repo.Update(id, req); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *CartService) AddItem(id int, req models.CartItemRequest) (*models.Cart, error) {
+	req.Barcode = strings.TrimSpace(req.Barcode)
+	req.Unit = strings.ToLower(strings.TrimSpace(req.Unit))
+	if req.ProductID == 0 && req.Barcode == "" {
+		return nil, fmt.Errorf("product_id or barcode is required")
+	}
+	if req.ProductID == 0 && !models.IsValidBarcode(req.Barcode) {
+		return nil, fmt.Errorf("invalid barcode %q", req.Barcode)
+	}
+	if req.Quantity <= 0 {
+		return nil, fmt.Errorf("quantity must be greater than 0")
+	}
+	if err := s.repo.AddItem(id, req); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// SetItemQuantity changes the quantity of a product in the given unit, the base
+// unit when empty, in the cart; 0 removes it.
+func (s *CartService) SetItemQuantity(id, productID int, unit string, quantity int) (*models.Cart, error) {
+	if quantity < 0 {
+		return nil, fmt.Errorf("quantity cannot be negative")
+	}
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	if err := s.repo.SetItemQuantity(id, productID, unit, quantity); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// Park puts an open cart aside so the cashier can serve someone else, holding
+// its stock until the reservation expires.
+func (s *CartService) Park(id int) (*models.Cart, error) {
+	if err := s.repo.Park(id, models.GetCurrentTime().Add(s.reservationTTL)); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// Resume reopens a parked cart so it can be changed again, releasing its stock.
+func (s *CartService) Resume(id int) (*models.Cart, error) {
+	return s.setStatus(id, models.CartStatusOpen, models.CartStatusParked)
+}
+
+func (s *CartService) Cancel(id int) (*models.Cart, error) {
+	return s.setStatus(id, models.CartStatusCancelled, models.CartStatusOpen, models.CartStatusParked)
+}
+
+func (s *CartService) setStatus(id int, status string, from ...string) (*models.Cart, error) {
+	if err := s.repo.SetStatus(id, status, from...); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// Checkout pays for an open or parked cart through TransactionService.Checkout,
+// so it is priced, discounted and taxed exactly like a direct checkout. The
+// checkout transaction locks the cart again and marks it checked_out, so a
+// failed or interrupted checkout leaves the cart as it was.
+func (s *CartService) Checkout(id int, req models.CartCheckoutRequest, useLock bool) (*models.Transaction, error) {
+	cart, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if cart.Status != models.CartStatusOpen && cart.Status != models.CartStatusParked {
+		return nil, fmt.Errorf("cart id %d is already %s", id, cart.Status)
+	}
+	if len(cart.Items) == 0 {
+		return nil, fmt.Errorf("cart id %d has no items", id)
+	}
+
+	checkout := models.CheckoutRequest{
+		Items:       make([]models.CheckoutItem, 0, len(cart.Items)),
+		Payments:    req.Payments,
+		Payment:     req.Payment,
+		PromoCode:   cart.PromoCode,
+		RegisterID:  req.RegisterID,
+		CashierID:   req.CashierID,
+		CashierName: req.CashierName,
+		CartID:      id,
+	}
+	for _, item := range cart.Items {
+		checkout.Items = append(checkout.Items, models.CheckoutItem{ProductID: item.ProductID, Unit: item.Unit, Quantity: item.Quantity})
+	}
+
+	return s.transactions.Checkout(checkout, useLock)
+}
+
+// ExpireReservations releases lapsed stock reservations every interval until
+// ctx is done.
+func (s *CartService) ExpireReservations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.repo.ExpireReservations()
+			if err != nil {
+				log.Printf("failed to expire stock reservations: %v", err)
+			} else if n > 0 {
+				log.Printf("released %d expired stock reservations", n)
+			}
+		}
+	}
+}
@@ -13,8 +13,8 @@ func NewCategoryService(repo *repositories.CategoryRepository) *CategoryService
 	return &CategoryService{repo: repo}
 }
 
-func (s *CategoryService) GetAll() ([]models.Category, error) {
-	return s.repo.GetAll()
+func (s *CategoryService) GetAll(includeArchived bool) ([]models.Category, error) {
+	return s.repo.GetAll(includeArchived)
 }
 
 func (s *CategoryService) GetByID(id int) (*models.Category, error) {
@@ -31,4 +31,12 @@ func (s *CategoryService) Update(category *models.Category) error {
 
 func (s *CategoryService) Delete(id int) error {
 	return s.repo.Delete(id)
-}
\ No newline at end of file
+}
+
+// Restore un-archives the category and returns it.
+func (s *CategoryService) Restore(id int) (*models.Category, error) {
+	if err := s.repo.Restore(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"strings"
+)
+
+type DiscountService struct {
+	repo *repositories.DiscountRepository
+}
+
+func NewDiscountService(repo *repositories.DiscountRepository) *DiscountService {
+	return &DiscountService{repo: repo}
+}
+
+func (s *DiscountService) GetAll() ([]models.Discount, error) {
+	return s.repo.GetAll()
+}
+
+func (s *DiscountService) GetByID(id int) (*models.Discount, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *DiscountService) Create(discount *models.Discount) error {
+	if err := validateDiscount(discount); err != nil {
+		return err
+	}
+	return s.repo.Create(discount)
+}
+
+func (s *DiscountService) Update(discount *models.Discount) error {
+	if err := validateDiscount(discount); err != nil {
+		return err
+	}
+	return s.repo.Update(discount)
+}
+
+func (s *DiscountService) Delete(id int) error {
+	return s.repo.Delete(id)
+}
+
+// validateDiscount checks the rule is consistent and normalizes the promo code to upper case.
+func validateDiscount(d *models.Discount) error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	switch d.Type {
+	case models.DiscountTypePercentage:
+		if d.Value <= 0 || d.Value > 100 {
+			return fmt.Errorf("percentage value must be between 1 and 100")
+		}
+	case models.DiscountTypeFixed:
+		if d.Value <= 0 {
+			return fmt.Errorf("fixed value must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("invalid type %q (percentage, fixed)", d.Type)
+	}
+
+	switch d.Scope {
+	case models.DiscountScopeProduct:
+		if d.ProductID == nil {
+			return fmt.Errorf("product_id is required for product scope")
+		}
+		d.CategoryID = nil
+	case models.DiscountScopeCategory:
+		if d.CategoryID == nil {
+			return fmt.Errorf("category_id is required for category scope")
+		}
+		d.ProductID = nil
+	case models.DiscountScopeCart:
+		d.ProductID, d.CategoryID = nil, nil
+	default:
+		return fmt.Errorf("invalid scope %q (product, category, cart)", d.Scope)
+	}
+
+	if d.MinPurchase < 0 {
+		return fmt.Errorf("min_purchase cannot be negative")
+	}
+	if d.StartsAt != nil && d.EndsAt != nil && d.EndsAt.Before(*d.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+
+	if d.PromoCode != nil {
+		code := strings.ToUpper(strings.TrimSpace(*d.PromoCode))
+		if code == "" {
+			d.PromoCode = nil
+		} else {
+			d.PromoCode = &code
+		}
+	}
+
+	return nil
+}
@@ -1,24 +1,33 @@
 package services
 
 import (
+	"fmt"
 	"kasir-api/models"
 	"kasir-api/repositories"
+	"strings"
 )
 
 type ProductService struct {
-	repo *repositories.ProductRepository
+	repo  *repositories.ProductRepository
+	stock *repositories.StockRepository
 }
 
-func NewProductService(repo *repositories.ProductRepository) *ProductService {
-	return &ProductService{repo: repo}
+func NewProductService(repo *repositories.ProductRepository, stock *repositories.StockRepository) *ProductService {
+	return &ProductService{repo: repo, stock: stock}
 }
 
-func (s *ProductService) GetAll(name string) ([]models.Product, error) {
-	return s.repo.GetAll(name)
+func (s *ProductService) GetAll(name string, includeArchived bool) ([]models.Product, error) {
+	return s.repo.GetAll(name, includeArchived)
 }
 
-func (s *ProductService) Create(data *models.Product) error {
-	return s.repo.Create(data)
+func (s *ProductService) Create(data *models.Product, userID int) error {
+	if strings.TrimSpace(data.BaseUnit) == "" {
+		data.BaseUnit = models.DefaultBaseUnit
+	}
+	if err := validateProduct(data); err != nil {
+		return err
+	}
+	return s.repo.Create(data, userID)
 }
 
 func (s *ProductService) GetByID(id int) (*models.Product, error) {
@@ -26,9 +35,158 @@ func (s *ProductService) GetByID(id int) (*models.Product, error) {
 }
 
 func (s *ProductService) Update(product *models.Product) error {
+	// Units are checked against the stored base unit when none is sent
+	if strings.TrimSpace(product.BaseUnit) == "" {
+		current, err := s.repo.GetByID(product.ID)
+		if err != nil {
+			return err
+		}
+		product.BaseUnit = current.BaseUnit
+	}
+	if err := validateProduct(product); err != nil {
+		return err
+	}
 	return s.repo.Update(product)
 }
 
+// GetByBarcode looks up the product for a scanned barcode.
+func (s *ProductService) GetByBarcode(code string) (*models.Product, error) {
+	if !models.IsValidBarcode(code) {
+		return nil, fmt.Errorf("invalid barcode %q (EAN-8, UPC-A or EAN-13 with check digit)", code)
+	}
+	return s.repo.GetByBarcode(code)
+}
+
+// GetLowStock lists the products that have reached their reorder point.
+func (s *ProductService) GetLowStock() ([]models.Product, error) {
+	return s.repo.GetLowStock()
+}
+
+func validateProduct(p *models.Product) error {
+	p.SKU = strings.TrimSpace(p.SKU)
+	seen := make(map[string]bool)
+	for i, code := range p.Barcodes {
+		code = strings.TrimSpace(code)
+		if !models.IsValidBarcode(code) {
+			return fmt.Errorf("invalid barcode %q (EAN-8, UPC-A or EAN-13 with check digit)", code)
+		}
+		if seen[code] {
+			return fmt.Errorf("barcode %s listed twice", code)
+		}
+		seen[code] = true
+		p.Barcodes[i] = code
+	}
+	if p.CostPrice != nil && *p.CostPrice < 0 {
+		return fmt.Errorf("cost_price cannot be negative")
+	}
+
+	// Unit names are compared case-insensitively at checkout
+	p.BaseUnit = strings.ToLower(strings.TrimSpace(p.BaseUnit))
+	units := make(map[string]bool)
+	for i := range p.Units {
+		u := &p.Units[i]
+		u.Unit = strings.ToLower(strings.TrimSpace(u.Unit))
+		switch {
+		case u.Unit == "":
+			return fmt.Errorf("unit name is required")
+		case len(u.Unit) > 20:
+			return fmt.Errorf("unit %s is longer than 20 characters", u.Unit)
+		case u.Unit == p.BaseUnit:
+			return fmt.Errorf("unit %s is the base unit", u.Unit)
+		case units[u.Unit]:
+			return fmt.Errorf("unit %s listed twice", u.Unit)
+		case u.Factor < 2:
+			return fmt.Errorf("factor of unit %s must be at least 2", u.Unit)
+		case u.Price <= 0:
+			return fmt.Errorf("price of unit %s must be greater than 0", u.Unit)
+		}
+		units[u.Unit] = true
+	}
+	if len(p.BaseUnit) > 20 {
+		return fmt.Errorf("base_unit is longer than 20 characters")
+	}
+	if p.ReorderPoint != nil && *p.ReorderPoint < 0 {
+		return fmt.Errorf("reorder_point cannot be negative")
+	}
+	if p.ReorderQty < 0 {
+		return fmt.Errorf("reorder_qty cannot be negative")
+	}
+	return nil
+}
+
+// AdjustStock applies a relative stock change with its reason.
+func (s *ProductService) AdjustStock(productID int, req models.StockAdjustmentRequest, userID int) (*models.StockMovement, error) {
+	movementType := models.StockMovementAdjustment
+	switch req.Reason {
+	case models.StockReasonRestock:
+		if req.Quantity <= 0 {
+			return nil, fmt.Errorf("restock quantity must be greater than 0")
+		}
+		movementType = models.StockMovementRestock
+	case models.StockReasonDamaged, models.StockReasonExpired:
+		if req.Quantity >= 0 {
+			return nil, fmt.Errorf("%s quantity must be negative", req.Reason)
+		}
+	case models.StockReasonCorrection:
+		if req.Quantity == 0 {
+			return nil, fmt.Errorf("quantity cannot be 0")
+		}
+	default:
+		return nil, fmt.Errorf("invalid reason %q (restock, damaged, expired, correction)", req.Reason)
+	}
+
+	reason := req.Reason
+	if note := strings.TrimSpace(req.Note); note != "" {
+		reason += ": " + note
+	}
+
+	movement := models.StockMovement{
+		ProductID: productID,
+		Type:      movementType,
+		Quantity:  req.Quantity,
+		Reason:    reason,
+	}
+	if userID > 0 {
+		movement.UserID = &userID
+	}
+	if err := s.stock.Adjust(&movement); err != nil {
+		return nil, err
+	}
+
+	return &movement, nil
+}
+
 func (s *ProductService) Delete(id int) error {
 	return s.repo.Delete(id)
 }
+
+// Restore un-archives the product and returns it.
+func (s *ProductService) Restore(id int) (*models.Product, error) {
+	if err := s.repo.Restore(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// StockHistory returns a page of the product's stock ledger, newest first.
+func (s *ProductService) StockHistory(productID, page, limit int) (*models.StockMovementList, error) {
+	if _, err := s.repo.GetByID(productID); err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	movements, total, err := s.stock.GetMovements(productID, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.StockMovementList{Data: movements, Page: page, Limit: limit, Total: total}, nil
+}
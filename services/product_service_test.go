@@ -0,0 +1,39 @@
+package services
+
+import (
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUpdateProduct_ChecksUnitsAgainstStoredBaseUnit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	service := NewProductService(repositories.NewProductRepository(db), nil)
+
+	// Kecap is stocked per btl; the update leaves base_unit out
+	mock.ExpectQuery("WHERE p.id = \\$1").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "sku", "name", "price", "cost_price", "stock", "base_unit", "category_id", "category_name", "tax_exempt", "reorder_point", "reorder_qty", "archived_at", "reserved"}).
+			AddRow(4, "", "Kecap", 12000, 9000, 10, "btl", 1, "Makanan", false, nil, 0, nil, 0))
+	mock.ExpectQuery("FROM product_barcodes WHERE product_id = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "code"}))
+	mock.ExpectQuery("FROM product_units WHERE product_id = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "unit", "factor", "price"}))
+
+	product := models.Product{ID: 4, Name: "Kecap", Price: 12000, Units: []models.ProductUnit{{Unit: "BTL", Factor: 12, Price: 140000}}}
+	err = service.Update(&product)
+	if err == nil || err.Error() != "unit btl is the base unit" {
+		t.Fatalf("expected the unit to clash with the stored base unit, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"strings"
+)
+
+type PurchaseOrderService struct {
+	repo *repositories.PurchaseOrderRepository
+}
+
+func NewPurchaseOrderService(repo *repositories.PurchaseOrderRepository) *PurchaseOrderService {
+	return &PurchaseOrderService{repo: repo}
+}
+
+func (s *PurchaseOrderService) GetAll(filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	switch filter.Status {
+	case "", models.PurchaseOrderStatusDraft, models.PurchaseOrderStatusOrdered, models.PurchaseOrderStatusPartiallyReceived,
+		models.PurchaseOrderStatusReceived, models.PurchaseOrderStatusCancelled:
+	default:
+		return nil, fmt.Errorf("invalid status %q (draft, ordered, partially_received, received, cancelled)", filter.Status)
+	}
+	return s.repo.GetAll(filter)
+}
+
+func (s *PurchaseOrderService) GetByID(id int) (*models.PurchaseOrder, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *PurchaseOrderService) Create(req models.PurchaseOrderRequest) (*models.PurchaseOrder, error) {
+	if err := validatePurchaseOrder(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := s.repo.Create(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *PurchaseOrderService) Update(id int, req models.PurchaseOrderRequest) (*models.PurchaseOrder, error) {
+	if err := validatePurchaseOrder(&req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateDraft(id, req); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *PurchaseOrderService) Order(id int) (*models.PurchaseOrder, error) {
+	if err := s.repo.Order(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *PurchaseOrderService) Cancel(id int) (*models.PurchaseOrder, error) {
+	if err := s.repo.Cancel(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// Receive books a delivery and returns the updated purchase order.
+func (s *PurchaseOrderService) Receive(id int, req models.ReceiveGoodsRequest) (*models.PurchaseOrder, error) {
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("items are required")
+	}
+	seen := make(map[int]bool)
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity must be greater than 0")
+		}
+		if item.UnitCost != nil && *item.UnitCost < 0 {
+			return nil, fmt.Errorf("unit_cost cannot be negative")
+		}
+		if seen[item.ProductID] {
+			return nil, fmt.Errorf("product id %d is listed twice", item.ProductID)
+		}
+		seen[item.ProductID] = true
+	}
+	req.Notes = strings.TrimSpace(req.Notes)
+
+	if _, err := s.repo.Receive(id, req); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+func validatePurchaseOrder(req *models.PurchaseOrderRequest) error {
+	if req.SupplierID <= 0 {
+		return fmt.Errorf("supplier_id is required")
+	}
+	if len(req.Items) == 0 {
+		return fmt.Errorf("items are required")
+	}
+	seen := make(map[int]bool)
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0")
+		}
+		if item.UnitCost < 0 {
+			return fmt.Errorf("unit_cost cannot be negative")
+		}
+		if seen[item.ProductID] {
+			return fmt.Errorf("product id %d is listed twice", item.ProductID)
+		}
+		seen[item.ProductID] = true
+	}
+	req.Notes = strings.TrimSpace(req.Notes)
+
+	return nil
+}
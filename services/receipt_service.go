@@ -0,0 +1,25 @@
+package services
+
+import (
+	"kasir-api/receipt"
+	"kasir-api/repositories"
+)
+
+type ReceiptService struct {
+	repo     repositories.TransactionRepository
+	renderer *receipt.Renderer
+}
+
+func NewReceiptService(repo repositories.TransactionRepository, renderer *receipt.Renderer) *ReceiptService {
+	return &ReceiptService{repo: repo, renderer: renderer}
+}
+
+// Render returns the receipt of a transaction in the requested format and its content type.
+func (s *ReceiptService) Render(transactionID int, format string) ([]byte, string, error) {
+	transaction, err := s.repo.GetTransactionByID(transactionID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.renderer.Render(transaction, format)
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"strings"
+)
+
+type ShiftService struct {
+	repo *repositories.ShiftRepository
+}
+
+func NewShiftService(repo *repositories.ShiftRepository) *ShiftService {
+	return &ShiftService{repo: repo}
+}
+
+func (s *ShiftService) GetAll(status string) ([]models.Shift, error) {
+	if status != "" && status != models.ShiftStatusOpen && status != models.ShiftStatusClosed {
+		return nil, fmt.Errorf("invalid status %q (open, closed)", status)
+	}
+	return s.repo.GetAll(status)
+}
+
+func (s *ShiftService) GetCurrent(cashierID int) (*models.Shift, error) {
+	return s.repo.GetOpenByCashier(cashierID)
+}
+
+func (s *ShiftService) Open(cashierID int, req models.OpenShiftRequest) (*models.Shift, error) {
+	req.RegisterID = strings.TrimSpace(req.RegisterID)
+	if req.RegisterID == "" {
+		return nil, fmt.Errorf("register_id is required")
+	}
+	if req.OpeningFloat < 0 {
+		return nil, fmt.Errorf("opening_float cannot be negative")
+	}
+
+	shift := models.Shift{
+		CashierID:    cashierID,
+		RegisterID:   req.RegisterID,
+		OpeningFloat: req.OpeningFloat,
+		OpenedAt:     models.GetCurrentTime(),
+	}
+	if err := s.repo.Open(&shift); err != nil {
+		return nil, err
+	}
+
+	return &shift, nil
+}
+
+// Report returns the shift report. Cashiers may only see their own shifts.
+func (s *ShiftService) Report(id, userID int, role string) (*models.ShiftReport, error) {
+	if err := s.checkOwner(id, userID, role); err != nil {
+		return nil, err
+	}
+	return s.repo.Report(id)
+}
+
+// Close counts the drawer and closes the shift. Cashiers may only close their own shifts.
+func (s *ShiftService) Close(id, userID int, role string, req models.CloseShiftRequest) (*models.ShiftReport, error) {
+	if req.CountedCash == nil {
+		return nil, fmt.Errorf("counted_cash is required")
+	}
+	if *req.CountedCash < 0 {
+		return nil, fmt.Errorf("counted_cash cannot be negative")
+	}
+	if err := s.checkOwner(id, userID, role); err != nil {
+		return nil, err
+	}
+
+	return s.repo.Close(id, *req.CountedCash, strings.TrimSpace(req.Notes))
+}
+
+func (s *ShiftService) checkOwner(id, userID int, role string) error {
+	if role == models.RoleAdmin {
+		return nil
+	}
+
+	shift, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if shift.CashierID != userID {
+		return fmt.Errorf("shift id %d belongs to another cashier", id)
+	}
+
+	return nil
+}
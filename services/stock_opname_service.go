@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"strings"
+)
+
+type StockOpnameService struct {
+	repo *repositories.StockOpnameRepository
+}
+
+func NewStockOpnameService(repo *repositories.StockOpnameRepository) *StockOpnameService {
+	return &StockOpnameService{repo: repo}
+}
+
+func (s *StockOpnameService) GetAll(status string) ([]models.StockOpname, error) {
+	switch status {
+	case "", models.StockOpnameStatusOpen, models.StockOpnameStatusCommitted, models.StockOpnameStatusCancelled:
+	default:
+		return nil, fmt.Errorf("invalid status %q (open, committed, cancelled)", status)
+	}
+	return s.repo.GetAll(status)
+}
+
+func (s *StockOpnameService) Start(req models.StartStockOpnameRequest, userID int) (*models.StockOpname, error) {
+	opname := models.StockOpname{
+		Notes:     strings.TrimSpace(req.Notes),
+		StartedAt: models.GetCurrentTime(),
+	}
+	if userID > 0 {
+		opname.CreatedBy = &userID
+	}
+	if err := s.repo.Start(&opname); err != nil {
+		return nil, err
+	}
+
+	return &opname, nil
+}
+
+// SubmitCounts records counted quantities and returns the updated variance preview.
+func (s *StockOpnameService) SubmitCounts(id int, req models.StockOpnameCountRequest) (*models.StockOpnameReport, error) {
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("items are required")
+	}
+	for _, item := range req.Items {
+		if item.CountedQuantity == nil {
+			return nil, fmt.Errorf("counted_quantity is required for product id %d", item.ProductID)
+		}
+		if *item.CountedQuantity < 0 {
+			return nil, fmt.Errorf("counted_quantity cannot be negative")
+		}
+	}
+
+	if err := s.repo.SaveCounts(id, req.Items); err != nil {
+		return nil, err
+	}
+	return s.repo.Report(id)
+}
+
+func (s *StockOpnameService) Report(id int) (*models.StockOpnameReport, error) {
+	return s.repo.Report(id)
+}
+
+func (s *StockOpnameService) Commit(id, userID int) (*models.StockOpnameReport, error) {
+	var committedBy *int
+	if userID > 0 {
+		committedBy = &userID
+	}
+	return s.repo.Commit(id, committedBy)
+}
+
+func (s *StockOpnameService) Cancel(id int) (*models.StockOpname, error) {
+	if err := s.repo.Cancel(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"fmt"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"strings"
+)
+
+type SupplierService struct {
+	repo *repositories.SupplierRepository
+}
+
+func NewSupplierService(repo *repositories.SupplierRepository) *SupplierService {
+	return &SupplierService{repo: repo}
+}
+
+func (s *SupplierService) GetAll() ([]models.Supplier, error) {
+	return s.repo.GetAll()
+}
+
+func (s *SupplierService) GetByID(id int) (*models.Supplier, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *SupplierService) Create(supplier *models.Supplier) error {
+	if err := validateSupplier(supplier); err != nil {
+		return err
+	}
+	return s.repo.Create(supplier)
+}
+
+func (s *SupplierService) Update(supplier *models.Supplier) error {
+	if err := validateSupplier(supplier); err != nil {
+		return err
+	}
+	return s.repo.Update(supplier)
+}
+
+func (s *SupplierService) Delete(id int) error {
+	return s.repo.Delete(id)
+}
+
+func validateSupplier(supplier *models.Supplier) error {
+	supplier.Name = strings.TrimSpace(supplier.Name)
+	if supplier.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
@@ -2,34 +2,129 @@ package services
 
 import (
 	"fmt"
+	"kasir-api/alerts"
 	"kasir-api/models"
 	"kasir-api/repositories"
+	"strings"
 	"time"
 )
 
 type TransactionService struct {
-	repo repositories.TransactionRepository
+	repo     repositories.TransactionRepository
+	notifier alerts.Notifier
 }
 
-func NewTransactionService(repo repositories.TransactionRepository) *TransactionService {
-	return &TransactionService{repo: repo}
+func NewTransactionService(repo repositories.TransactionRepository, notifier alerts.Notifier) *TransactionService {
+	return &TransactionService{repo: repo, notifier: notifier}
 }
 
-func (s *TransactionService) Checkout(items []models.CheckoutItem, useLock bool) (*models.Transaction, error) {
-	return s.repo.CreateTransaction(items, useLock)
+func (s *TransactionService) Checkout(req models.CheckoutRequest, useLock bool) (*models.Transaction, error) {
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("items is required, add at least one item")
+	}
+	req.RegisterID = strings.TrimSpace(req.RegisterID)
+	if req.Payment != nil {
+		req.Payments = append(req.Payments, *req.Payment)
+		req.Payment = nil
+	}
+	if len(req.Payments) == 0 {
+		return nil, fmt.Errorf("payment is required")
+	}
+	for _, p := range req.Payments {
+		if !models.IsValidPaymentMethod(p.Method) {
+			return nil, fmt.Errorf("invalid payment method %q (cash, qris, debit, ewallet)", p.Method)
+		}
+		if p.AmountTendered <= 0 {
+			return nil, fmt.Errorf("payment amount_tendered must be greater than 0")
+		}
+	}
+
+	for i, item := range req.Items {
+		if item.ProductID == 0 && item.Barcode != "" && !models.IsValidBarcode(item.Barcode) {
+			return nil, fmt.Errorf("invalid barcode %q", item.Barcode)
+		}
+		req.Items[i].Unit = strings.ToLower(strings.TrimSpace(item.Unit))
+	}
+
+	transaction, err := s.repo.CreateTransaction(req, useLock)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range transaction.LowStock {
+		s.notifier.LowStock(event)
+	}
+
+	return transaction, nil
+}
+
+func (s *TransactionService) GetTransactions(filter models.TransactionFilter) (*models.TransactionList, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 20
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+
+	transactions, total, err := s.repo.GetTransactions(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TransactionList{
+		Data:  transactions,
+		Page:  filter.Page,
+		Limit: filter.Limit,
+		Total: total,
+	}, nil
+}
+
+func (s *TransactionService) GetTransactionByID(id int) (*models.Transaction, error) {
+	return s.repo.GetTransactionByID(id)
+}
+
+// Void cancels the whole transaction, refunding every item that has not been refunded yet.
+// Any items in req are ignored.
+func (s *TransactionService) Void(id int, req models.RefundRequest) (*models.Refund, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+
+	req.Items = nil
+	return s.repo.RefundTransaction(id, req, true)
+}
+
+// Refund returns part of a transaction, item by item.
+func (s *TransactionService) Refund(id int, req models.RefundRequest) (*models.Refund, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("items are required")
+	}
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity must be greater than 0")
+		}
+	}
+
+	return s.repo.RefundTransaction(id, req, false)
 }
 
-func (s *TransactionService) GetDailyReport() (*models.SalesSummary, error) {
+func (s *TransactionService) GetDailyReport(groupBy string) (*models.SalesSummary, error) {
 	// Start of Day
 	now := time.Now()
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	// End of Day (until next day 00:00:00 basically, or just use now if we want real-time up to now)
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
-	return s.repo.GetSalesSummary(startOfDay, endOfDay)
+	return s.salesSummary(startOfDay, endOfDay, groupBy)
 }
 
-func (s *TransactionService) GetReport(startDateStr, endDateStr string) (*models.SalesSummary, error) {
+func (s *TransactionService) GetReport(startDateStr, endDateStr, groupBy string) (*models.SalesSummary, error) {
 	layout := "2006-01-02"
 	startDate, err := time.ParseInLocation(layout, startDateStr, time.Local)
 	if err != nil {
@@ -44,5 +139,63 @@ func (s *TransactionService) GetReport(startDateStr, endDateStr string) (*models
 	// Adjust endDate to include the full day
 	endDate = endDate.Add(24 * time.Hour)
 
-	return s.repo.GetSalesSummary(startDate, endDate)
+	return s.salesSummary(startDate, endDate, groupBy)
+}
+
+// GetMarginReport returns gross profit per product, category or day over the
+// period, product being the default.
+func (s *TransactionService) GetMarginReport(startDateStr, endDateStr, groupBy string) (*models.MarginReport, error) {
+	layout := "2006-01-02"
+	startDate, err := time.ParseInLocation(layout, startDateStr, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start_date format (YYYY-MM-DD)")
+	}
+	endDate, err := time.ParseInLocation(layout, endDateStr, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end_date format (YYYY-MM-DD)")
+	}
+
+	if groupBy == "" {
+		groupBy = models.ReportGroupByProduct
+	}
+	if groupBy != models.ReportGroupByProduct && groupBy != models.ReportGroupByCategory && groupBy != models.ReportGroupByDay {
+		return nil, fmt.Errorf("invalid group_by %q (product, category, day)", groupBy)
+	}
+
+	rows, err := s.repo.GetMargins(startDate, endDate.Add(24*time.Hour), groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	report := models.MarginReport{StartDate: startDateStr, EndDate: endDateStr, GroupBy: groupBy, Rows: rows}
+	for _, row := range rows {
+		report.NetSales += row.NetSales
+		report.COGS += row.COGS
+	}
+	report.GrossProfit = report.NetSales - report.COGS
+	report.GrossMargin = models.Margin(report.GrossProfit, report.NetSales)
+
+	return &report, nil
+}
+
+// salesSummary builds the report for the period, broken down per cashier or per
+// register when groupBy is set.
+func (s *TransactionService) salesSummary(startDate, endDate time.Time, groupBy string) (*models.SalesSummary, error) {
+	if groupBy != "" && groupBy != models.ReportGroupByCashier && groupBy != models.ReportGroupByRegister {
+		return nil, fmt.Errorf("invalid group_by %q (cashier, register)", groupBy)
+	}
+
+	summary, err := s.repo.GetSalesSummary(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if groupBy != "" {
+		summary.Groups, err = s.repo.GetSalesByGroup(startDate, endDate, groupBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return summary, nil
 }
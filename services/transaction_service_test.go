@@ -0,0 +1,31 @@
+package services
+
+import (
+	"kasir-api/alerts"
+	"kasir-api/models"
+	"kasir-api/repositories"
+	"strings"
+	"testing"
+)
+
+// stubTransactionRepository fails the test if checkout reaches the database.
+type stubTransactionRepository struct {
+	repositories.TransactionRepository
+	t *testing.T
+}
+
+func (r stubTransactionRepository) CreateTransaction(req models.CheckoutRequest, useLock bool) (*models.Transaction, error) {
+	r.t.Fatalf("CreateTransaction was not expected for %+v", req)
+	return nil, nil
+}
+
+func TestCheckout_RejectsEmptyItems(t *testing.T) {
+	service := NewTransactionService(stubTransactionRepository{t: t}, alerts.LogNotifier{})
+
+	_, err := service.Checkout(models.CheckoutRequest{
+		Payments: []models.PaymentRequest{{Method: models.PaymentMethodCash, AmountTendered: 5000}},
+	}, false)
+	if err == nil || !strings.Contains(err.Error(), "at least one item") {
+		t.Fatalf("expected empty checkout to be rejected, got %v", err)
+	}
+}
@@ -9,9 +9,22 @@ if ! curl -s "$BASE_URL/health" > /dev/null; then
     exit 1
 fi
 
+# Log in as admin (ADMIN_USERNAME/ADMIN_PASSWORD of the running server)
+ADMIN_USERNAME="${ADMIN_USERNAME:-admin}"
+LOGIN=$(curl -s -X POST "$BASE_URL/api/auth/login" -d "{\"username\":\"$ADMIN_USERNAME\",\"password\":\"$ADMIN_PASSWORD\"}" -H "Content-Type: application/json")
+TOKEN=$(echo $LOGIN | grep -o '"token":"[^"]*"' | cut -d'"' -f4)
+if [ -z "$TOKEN" ]; then
+    echo "Error: Login failed. Set ADMIN_PASSWORD to the admin password of the server. Response: $LOGIN"
+    exit 1
+fi
+AUTH="Authorization: Bearer $TOKEN"
+
+# Checkout needs an open shift; an already open one from an earlier run is fine
+curl -s -H "$AUTH" -X POST "$BASE_URL/api/shifts/open" -d '{"register_id":"VERIFY-1","opening_float":0}' -H "Content-Type: application/json" > /dev/null
+
 # 1. Test Negative Price (Product)
 echo "1. Testing Negative Price..."
-RESPONSE=$(curl -s -X POST "$BASE_URL/api/produk" -d '{"name":"Test Bad Price","price":-5000,"stock":10,"category_id":1}' -H "Content-Type: application/json")
+RESPONSE=$(curl -s -H "$AUTH" -X POST "$BASE_URL/api/produk" -d '{"name":"Test Bad Price","price":-5000,"stock":10,"category_id":1}' -H "Content-Type: application/json")
 if [[ "$RESPONSE" == *"Price cannot be negative"* ]]; then
   echo "PASS: Negative Price Rejected"
 else
@@ -20,7 +33,7 @@ fi
 
 # 2. Test Negative Stock (Product)
 echo "2. Testing Negative Stock..."
-RESPONSE=$(curl -s -X POST "$BASE_URL/api/produk" -d '{"name":"Test Bad Stock","price":5000,"stock":-10,"category_id":1}' -H "Content-Type: application/json")
+RESPONSE=$(curl -s -H "$AUTH" -X POST "$BASE_URL/api/produk" -d '{"name":"Test Bad Stock","price":5000,"stock":-10,"category_id":1}' -H "Content-Type: application/json")
 if [[ "$RESPONSE" == *"Stock cannot be negative"* ]]; then
   echo "PASS: Negative Stock Rejected"
 else
@@ -29,7 +42,7 @@ fi
 
 # 3. Create Valid Product for Checkout Tests
 echo "3. Creating Valid Product..."
-VALID_PRODUCT=$(curl -s -X POST "$BASE_URL/api/produk" -d '{"name":"Test Checkout Item","price":1000,"stock":5,"category_id":1}' -H "Content-Type: application/json")
+VALID_PRODUCT=$(curl -s -H "$AUTH" -X POST "$BASE_URL/api/produk" -d '{"name":"Test Checkout Item","price":1000,"stock":5,"category_id":1}' -H "Content-Type: application/json")
 # Extract ID using grep if available, or just rely on manual check if complicated json parsing is needed without jq
 # Assuming simple regex works for ID
 PRODUCT_ID=$(echo $VALID_PRODUCT | grep -o '"id":[0-9]*' | grep -o '[0-9]*')
@@ -42,7 +55,7 @@ echo "Created Product ID: $PRODUCT_ID"
 
 # 4. Test Checkout Negative Quantity
 echo "4. Testing Checkout Negative Quantity..."
-RESPONSE=$(curl -s -X POST "$BASE_URL/api/checkout" -d "{\"items\":[{\"product_id\":$PRODUCT_ID,\"quantity\":-1}]}" -H "Content-Type: application/json")
+RESPONSE=$(curl -s -H "$AUTH" -X POST "$BASE_URL/api/checkout" -d "{\"items\":[{\"product_id\":$PRODUCT_ID,\"quantity\":-1}],\"payment\":{\"method\":\"cash\",\"amount_tendered\":100000}}" -H "Content-Type: application/json")
 if [[ "$RESPONSE" == *"Quantity must be greater than 0"* ]]; then
   echo "PASS: Negative Quantity Rejected"
 else
@@ -51,7 +64,7 @@ fi
 
 # 5. Test Checkout Insufficient Stock
 echo "5. Testing Checkout Insufficient Stock (Req: 10, Stock: 5)..."
-RESPONSE=$(curl -s -X POST "$BASE_URL/api/checkout" -d "{\"items\":[{\"product_id\":$PRODUCT_ID,\"quantity\":10}]}" -H "Content-Type: application/json")
+RESPONSE=$(curl -s -H "$AUTH" -X POST "$BASE_URL/api/checkout" -d "{\"items\":[{\"product_id\":$PRODUCT_ID,\"quantity\":10}],\"payment\":{\"method\":\"cash\",\"amount_tendered\":100000}}" -H "Content-Type: application/json")
 if [[ "$RESPONSE" == *"insufficient stock"* ]]; then
   echo "PASS: Insufficient Stock Rejected"
 else
@@ -60,7 +73,7 @@ fi
 
 # 6. Test Successful Checkout
 echo "6. Testing Successful Checkout (Req: 2, Stock: 5)..."
-RESPONSE=$(curl -s -X POST "$BASE_URL/api/checkout" -d "{\"items\":[{\"product_id\":$PRODUCT_ID,\"quantity\":2}]}" -H "Content-Type: application/json")
+RESPONSE=$(curl -s -H "$AUTH" -X POST "$BASE_URL/api/checkout" -d "{\"items\":[{\"product_id\":$PRODUCT_ID,\"quantity\":2}],\"payment\":{\"method\":\"cash\",\"amount_tendered\":100000}}" -H "Content-Type: application/json")
 TRANSACTION_ID=$(echo $RESPONSE | grep -o '"id":[0-9]*' | grep -o '[0-9]*')
 
 if [ -n "$TRANSACTION_ID" ]; then
@@ -72,7 +85,7 @@ fi
 
 # 7. Test Stock Update (Should be 3)
 echo "7. Verifying Stock Update..."
-PRODUCT_INFO=$(curl -s "$BASE_URL/api/produk/$PRODUCT_ID")
+PRODUCT_INFO=$(curl -s -H "$AUTH" "$BASE_URL/api/produk/$PRODUCT_ID")
 STOCK=$(echo $PRODUCT_INFO | grep -o '"stock":[0-9]*' | grep -o '[0-9]*')
 if [ "$STOCK" == "3" ]; then
     echo "PASS: Stock Updated Correctly (3)"
@@ -82,11 +95,11 @@ fi
 
 # Cleanup
 echo "Cleaning up..."
-curl -s -X DELETE "$BASE_URL/api/produk/$PRODUCT_ID" > /dev/null
+curl -s -H "$AUTH" -X DELETE "$BASE_URL/api/produk/$PRODUCT_ID" > /dev/null
 
 # 8. Test Daily Report
 echo "8. Testing Daily Report..."
-REPORT=$(curl -s "$BASE_URL/api/report/hari-ini")
+REPORT=$(curl -s -H "$AUTH" "$BASE_URL/api/report/hari-ini")
 TOTAL_TRANS=$(echo $REPORT | grep -o '"total_transaksi":[0-9]*' | grep -o '[0-9]*')
 if [ -n "$TOTAL_TRANS" ] && [ "$TOTAL_TRANS" -gt 0 ]; then
     echo "PASS: Daily Report returned transactions: $TOTAL_TRANS"
@@ -98,7 +111,7 @@ fi
 echo "9. Testing Date Range Report..."
 # Use a wide range to ensure we catch today's transactions
 TODAY=$(date +%Y-%m-%d)
-RANGE_REPORT=$(curl -s "$BASE_URL/api/report?start_date=$TODAY&end_date=$TODAY")
+RANGE_REPORT=$(curl -s -H "$AUTH" "$BASE_URL/api/report?start_date=$TODAY&end_date=$TODAY")
 RANGE_TRANS=$(echo $RANGE_REPORT | grep -o '"total_transaksi":[0-9]*' | grep -o '[0-9]*')
 if [ "$RANGE_TRANS" == "$TOTAL_TRANS" ]; then
     echo "PASS: Date Range Report matches Daily Report ($RANGE_TRANS)"